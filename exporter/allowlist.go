@@ -0,0 +1,208 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// TargetAllowlist is the list of targets the /scrape endpoint may connect to. An entry is
+// a CIDR or IP address, a hostname or a glob pattern of hostnames like "*.cache.example.com",
+// or the path of a unix socket, which may be a glob pattern too. Hostnames that don't match
+// an entry are resolved and allowed if all of their addresses are in the allowed CIDRs.
+type TargetAllowlist struct {
+	prefixes []netip.Prefix
+	patterns []string // lower case
+
+	// lookup resolves hostnames, it's replaced by the tests
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+// NewTargetAllowlist parses the entries of the allowlist
+func NewTargetAllowlist(entries []string) (*TargetAllowlist, error) {
+	a := &TargetAllowlist{lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	}}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			a.prefixes = append(a.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			a.prefixes = append(a.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		if _, err := path.Match(entry, ""); err != nil {
+			return nil, fmt.Errorf("invalid target allowlist entry %q: %w", entry, err)
+		}
+		a.patterns = append(a.patterns, strings.ToLower(entry))
+	}
+	if len(a.prefixes) == 0 && len(a.patterns) == 0 {
+		return nil, fmt.Errorf("empty target allowlist")
+	}
+	return a, nil
+}
+
+func (a *TargetAllowlist) matchesPattern(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range a.patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *TargetAllowlist) containsAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range a.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns whether the target u is in the allowlist
+func (a *TargetAllowlist) allows(ctx context.Context, u *url.URL) (bool, error) {
+	host := u.Hostname()
+	if u.Scheme == "unix" || host == "" {
+		return a.matchesPattern(u.Path), nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return a.containsAddr(addr), nil
+	}
+	if a.matchesPattern(host) {
+		return true, nil
+	}
+	if len(a.prefixes) == 0 {
+		return false, nil
+	}
+
+	addrs, err := a.lookup(ctx, host)
+	if err != nil {
+		return false, fmt.Errorf("couldn't resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !a.containsAddr(addr) {
+			return false, nil
+		}
+	}
+	return len(addrs) > 0, nil
+}
+
+// resolvesHost returns whether u is only allowed because of the addresses its hostname resolves to,
+// the connections to u must then be made with dial as the hostname can resolve to other addresses
+// by the time the exporter connects (DNS rebinding)
+func (a *TargetAllowlist) resolvesHost(u *url.URL) bool {
+	host := u.Hostname()
+	if u.Scheme == "unix" || host == "" {
+		return false
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return false
+	}
+	return !a.matchesPattern(host) && len(a.prefixes) > 0
+}
+
+// dial resolves the host of address and connects to one of its addresses with d, if they're all allowed
+func (a *TargetAllowlist) dial(ctx context.Context, d *net.Dialer, network, address string) (net.Conn, error) {
+	if network == "unix" {
+		return d.DialContext(ctx, network, address)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else if addrs, err = a.lookup(ctx, host); err != nil {
+		return nil, fmt.Errorf("couldn't resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !a.containsAddr(addr) {
+			return nil, fmt.Errorf("address %s of %s is not allowed", addr, host)
+		}
+	}
+
+	err = fmt.Errorf("no addresses for %s", host)
+	for _, addr := range addrs {
+		var c net.Conn
+		if c, err = d.DialContext(ctx, network, net.JoinHostPort(addr.String(), port)); err == nil {
+			return c, nil
+		}
+	}
+	return nil, err
+}
+
+// targetKey identifies the instance at addr to compare targets, e.g. "localhost:6379"
+func targetKey(addr string) string {
+	if !strings.Contains(addr, "://") {
+		addr = "redis://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return addr
+	}
+	if u.Scheme == "unix" || u.Host == "" {
+		return u.Path
+	}
+	port := u.Port()
+	if port == "" {
+		port = "6379"
+	}
+	return net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// isKnownTarget returns whether u is in the password file or the targets of the config file
+func (e *Exporter) isKnownTarget(u *url.URL) bool {
+	key := targetKey(u.String())
+	for _, t := range e.options.Targets {
+		if targetKey(t.Addr) == key {
+			return true
+		}
+	}
+
+	e.RLock()
+	defer e.RUnlock()
+	for addr := range e.options.PasswordMap {
+		if targetKey(addr) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// isTargetAllowed returns whether /scrape may connect to u, targets are allowed if they're in
+// the allowlist or, if only known targets are allowed, in the password file or config file.
+// dialAllowlist is set if u is allowed because of the addresses of its hostname, then the
+// connections must only be made to allowed addresses, see Options.dialAllowlist.
+func (e *Exporter) isTargetAllowed(ctx context.Context, u *url.URL) (allowed bool, dialAllowlist *TargetAllowlist, err error) {
+	if e.options.ScrapeTargetAllowlist == nil && !e.options.ScrapeKnownTargetsOnly {
+		return true, nil, nil
+	}
+	if e.options.ScrapeKnownTargetsOnly && e.isKnownTarget(u) {
+		return true, nil, nil
+	}
+	a := e.options.ScrapeTargetAllowlist
+	if a == nil {
+		return false, nil, nil
+	}
+	if ok, err := a.allows(ctx, u); !ok {
+		return false, nil, err
+	}
+	if a.resolvesHost(u) {
+		return true, a, nil
+	}
+	return true, nil, nil
+}
@@ -0,0 +1,314 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// awsIAMTokenLifetime is how long ElastiCache and MemoryDB accept an IAM auth token
+	awsIAMTokenLifetime = 15 * time.Minute
+
+	// awsIAMTokenRefresh is how long before it expires a token is replaced by a new one
+	awsIAMTokenRefresh = 5 * time.Minute
+
+	awsSigningAlgorithm = "AWS4-HMAC-SHA256"
+	awsAmzDateFormat    = "20060102T150405Z"
+)
+
+// AWSIAMConfig is the "aws-iam" setting of a target or module in the config file,
+// the user of the target is the IAM enabled user of the cluster
+type AWSIAMConfig struct {
+	ClusterID  string `yaml:"cluster-id"`
+	Region     string `yaml:"region"`     // defaults to AWS_REGION, AWS_DEFAULT_REGION or the shared config file
+	Service    string `yaml:"service"`    // "elasticache" (default) or "memorydb"
+	Serverless bool   `yaml:"serverless"` // ElastiCache serverless cache
+
+	mtx       sync.Mutex
+	providers map[string]*AWSIAMCredentialProvider // by user
+}
+
+func (c *AWSIAMConfig) validate(user string) error {
+	if c.ClusterID == "" {
+		return errors.New("aws-iam: missing cluster-id")
+	}
+	if user == "" {
+		return errors.New("aws-iam: missing user")
+	}
+	switch c.Service {
+	case "", "elasticache", "memorydb":
+	default:
+		return fmt.Errorf("aws-iam: unknown service %q, expected elasticache or memorydb", c.Service)
+	}
+	if c.Serverless && c.Service == "memorydb" {
+		return errors.New("aws-iam: serverless is only supported for elasticache")
+	}
+	return nil
+}
+
+// credentialProvider returns the provider of the tokens for user, it's created once per user
+// so the token is reused by all the scrapes of the target or module
+func (c *AWSIAMConfig) credentialProvider(user string) *AWSIAMCredentialProvider {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if p, ok := c.providers[user]; ok {
+		return p
+	}
+	if c.providers == nil {
+		c.providers = map[string]*AWSIAMCredentialProvider{}
+	}
+	p := NewAWSIAMCredentialProvider(c, user)
+	c.providers[user] = p
+	return p
+}
+
+// AWSCredentials are the credentials used to sign the IAM auth tokens
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSIAMCredentialProvider generates the IAM auth tokens of ElastiCache and MemoryDB,
+// a token is the SigV4 presigned connect request of the user. The AWS credentials
+// are read from the environment or the shared credentials and config files.
+type AWSIAMCredentialProvider struct {
+	clusterID  string
+	region     string
+	service    string
+	serverless bool
+	user       string
+
+	// for the tests
+	now         func() time.Time
+	credentials func() (AWSCredentials, error)
+
+	mtx    sync.Mutex
+	cached Credentials
+}
+
+// NewAWSIAMCredentialProvider returns a provider of the tokens of user for the cluster in cfg
+func NewAWSIAMCredentialProvider(cfg *AWSIAMConfig, user string) *AWSIAMCredentialProvider {
+	service := cfg.Service
+	if service == "" {
+		service = "elasticache"
+	}
+	return &AWSIAMCredentialProvider{
+		clusterID:   cfg.ClusterID,
+		region:      cfg.Region,
+		service:     service,
+		serverless:  cfg.Serverless,
+		user:        user,
+		now:         time.Now,
+		credentials: loadAWSCredentials,
+	}
+}
+
+func (p *AWSIAMCredentialProvider) Credentials(_ context.Context, _ string) (Credentials, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	now := p.now()
+	if p.cached.Password != "" && now.Before(p.cached.ExpiresAt) {
+		return p.cached, nil
+	}
+
+	region := p.region
+	if region == "" {
+		region = awsRegion()
+	}
+	if region == "" {
+		return Credentials{}, errors.New("aws-iam: no region configured")
+	}
+	creds, err := p.credentials()
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	token := presignIAMAuthToken(creds, region, p.service, p.clusterID, p.user, p.serverless, now)
+	p.cached = Credentials{Username: p.user, Password: token, ExpiresAt: now.Add(awsIAMTokenLifetime - awsIAMTokenRefresh)}
+	return p.cached, nil
+}
+
+func (p *AWSIAMCredentialProvider) Invalidate(_ string) {
+	p.mtx.Lock()
+	p.cached = Credentials{}
+	p.mtx.Unlock()
+}
+
+// presignIAMAuthToken returns the IAM auth token, the presigned URL of the connect request without the scheme
+func presignIAMAuthToken(creds AWSCredentials, region, service, clusterID, user string, serverless bool, now time.Time) string {
+	query := url.Values{"Action": {"connect"}, "User": {user}}
+	if serverless {
+		query.Set("ResourceType", "ServerlessCache")
+	}
+	emptyPayloadHash := sha256.Sum256(nil)
+	return presignAWSRequest(creds, region, service, "GET", clusterID, "/", query, hex.EncodeToString(emptyPayloadHash[:]), awsIAMTokenLifetime, now)
+}
+
+// presignAWSRequest signs the request with AWS Signature Version 4 in the query string and returns it without the scheme,
+// see https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func presignAWSRequest(creds AWSCredentials, region, service, method, host, path string, query url.Values, payloadHash string, expires time.Duration, now time.Time) string {
+	now = now.UTC()
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+
+	query.Set("X-Amz-Algorithm", awsSigningAlgorithm)
+	query.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(awsAmzDateFormat))
+	query.Set("X-Amz-Expires", fmt.Sprintf("%d", int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if creds.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	canonicalQuery := awsCanonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{method, path, canonicalQuery, "host:" + host, "", "host", payloadHash}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{awsSigningAlgorithm, now.Format(awsAmzDateFormat), scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := awsSigningKey(creds.SecretAccessKey, date, region, service)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return host + path + "?" + canonicalQuery + "&X-Amz-Signature=" + signature
+}
+
+func awsSigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsCanonicalQuery returns the query sorted by name with the names and values URI encoded like AWS expects
+func awsCanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsURIEncode(name)+"="+awsURIEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func awsURIEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// awsRegion returns the region of the environment or of the profile in the shared config file
+func awsRegion() string {
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			return region
+		}
+	}
+	profile, _ := awsConfigProfile(awsSharedFile("AWS_CONFIG_FILE", "config"), true)
+	return profile["region"]
+}
+
+// loadAWSCredentials reads the credentials from the environment, the shared credentials file
+// or the shared config file, the profile is set by AWS_PROFILE
+func loadAWSCredentials() (AWSCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return AWSCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	for _, f := range []struct {
+		path     string
+		isConfig bool
+	}{
+		{path: awsSharedFile("AWS_SHARED_CREDENTIALS_FILE", "credentials")},
+		{path: awsSharedFile("AWS_CONFIG_FILE", "config"), isConfig: true},
+	} {
+		profile, err := awsConfigProfile(f.path, f.isConfig)
+		if err != nil {
+			return AWSCredentials{}, err
+		}
+		if profile["aws_access_key_id"] != "" && profile["aws_secret_access_key"] != "" {
+			return AWSCredentials{
+				AccessKeyID:     profile["aws_access_key_id"],
+				SecretAccessKey: profile["aws_secret_access_key"],
+				SessionToken:    profile["aws_session_token"],
+			}, nil
+		}
+	}
+	return AWSCredentials{}, errors.New("aws-iam: no AWS credentials found in the environment or the shared credentials and config files")
+}
+
+func awsSharedFile(env, name string) string {
+	if path := os.Getenv(env); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// awsConfigProfile returns the settings of the profile selected by AWS_PROFILE in the INI file at path,
+// in the config file the sections of profiles other than the default one are named "profile <name>"
+func awsConfigProfile(path string, isConfig bool) (map[string]string, error) {
+	name := os.Getenv("AWS_PROFILE")
+	if name == "" {
+		name = "default"
+	}
+	section := name
+	if isConfig && name != "default" {
+		section = "profile " + name
+	}
+
+	settings := map[string]string{}
+	if path == "" {
+		return settings, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.TrimSpace(line[1 : len(line)-1])
+		case current == section:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				settings[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return settings, scanner.Err()
+}
@@ -0,0 +1,153 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// backgroundResults holds the results of the collectors that run in the background.
+// Like the connection pools it is shared by all exporters created from the same Options.
+type backgroundResults struct {
+	sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	results map[string]*backgroundResult // by scrape key and collector name
+}
+
+type backgroundResult struct {
+	owner    *Exporter // the exporter that created the result, it's removed when the exporter is stopped
+	interval time.Duration
+	lastUsed time.Time
+
+	running     bool
+	lastStart   time.Time
+	lastRefresh time.Time
+
+	// metrics are the metrics of the last successful run
+	metrics []prometheus.Metric
+	// status are the collector duration, success and timeout metrics of the last run
+	status []prometheus.Metric
+}
+
+func newBackgroundResults() *backgroundResults {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &backgroundResults{ctx: ctx, cancel: cancel, results: map[string]*backgroundResult{}}
+}
+
+// stop cancels the runs that are in progress
+func (b *backgroundResults) stop() {
+	b.cancel()
+}
+
+// remove removes the results created by the exporter e, e.g. when it's evicted from the
+// cache of the /scrape endpoint
+func (b *backgroundResults) remove(e *Exporter) {
+	b.Lock()
+	defer b.Unlock()
+	for key, r := range b.results {
+		if r.owner == e {
+			delete(b.results, key)
+		}
+	}
+}
+
+// removeIdle removes the results that haven't been used for twice their interval, e.g. the ones of the
+// targets that aren't scraped via /scrape anymore, b must be locked
+func (b *backgroundResults) removeIdle(now time.Time) {
+	for key, r := range b.results {
+		if !r.running && now.Sub(r.lastUsed) > 2*r.interval {
+			delete(b.results, key)
+		}
+	}
+}
+
+// collectMetrics runs f and returns the metrics it sent
+func collectMetrics(f func(ch chan<- prometheus.Metric)) []prometheus.Metric {
+	var metrics []prometheus.Metric
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		for m := range ch {
+			metrics = append(metrics, m)
+		}
+		close(done)
+	}()
+
+	f(ch)
+	close(ch)
+	<-done
+	return metrics
+}
+
+// runInBackground sends the metrics of the last successful background run of the
+// collector to ch and starts a new run on its own connection once the interval
+// has passed since the previous run started
+func (e *Exporter) runInBackground(ch chan<- prometheus.Metric, name string, interval time.Duration, collect func(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn) error) {
+	b := e.options.background
+	key := e.scrapeKey + "\x00" + name
+
+	b.Lock()
+	now := time.Now()
+	b.removeIdle(now)
+	r, ok := b.results[key]
+	if !ok {
+		r = &backgroundResult{owner: e, interval: interval}
+		b.results[key] = r
+	}
+	r.lastUsed = now
+	if !r.running && time.Since(r.lastStart) >= interval && b.ctx.Err() == nil {
+		r.running = true
+		r.lastStart = time.Now()
+		go e.refreshInBackground(b, r, name, collect)
+	}
+	metrics, status, lastRefresh := r.metrics, r.status, r.lastRefresh
+	b.Unlock()
+
+	for _, m := range metrics {
+		ch <- m
+	}
+	for _, m := range status {
+		ch <- m
+	}
+	if !lastRefresh.IsZero() {
+		e.registerConstMetricGauge(ch, "exporter_collector_last_refresh_timestamp_seconds", float64(lastRefresh.UnixNano())/1e9, name)
+	}
+}
+
+func (e *Exporter) refreshInBackground(b *backgroundResults, r *backgroundResult, name string, collect func(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn) error) {
+	log.Debugf("Running collector %s of %s in the background", name, e.redisAddr)
+
+	var metrics []prometheus.Metric
+	var err error
+	status := collectMetrics(func(statusCh chan<- prometheus.Metric) {
+		err = e.runCollector(b.ctx, statusCh, name, func(ctx context.Context) error {
+			c, err := e.getConn(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var collectErr error
+			metrics = collectMetrics(func(ch chan<- prometheus.Metric) {
+				collectErr = collect(ctx, ch, withContext(ctx, c))
+			})
+			return collectErr
+		})
+	})
+
+	b.Lock()
+	defer b.Unlock()
+	r.running = false
+	r.status = status
+	if err != nil {
+		log.Errorf("Background run of collector %s failed, serving the previous result, err: %s", name, err)
+		return
+	}
+	r.metrics = metrics
+	r.lastRefresh = time.Now()
+}
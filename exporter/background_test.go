@@ -0,0 +1,126 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
+)
+
+func TestBackgroundCollectors(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+	s.Reply("SCAN", []interface{}{"0", []string{"test_a", "test_b"}})
+	e, _ := NewRedisExporter(s.URI(), Options{
+		Namespace:            "test",
+		CountKeys:            "db0=test_*",
+		BackgroundCollectors: map[string]time.Duration{"count_keys": time.Hour},
+	})
+	defer e.Stop()
+
+	scrape := func() string {
+		chM := make(chan prometheus.Metric)
+		go func() {
+			e.Collect(chM)
+			close(chM)
+		}()
+		var names []string
+		for m := range chM {
+			names = append(names, m.Desc().String())
+		}
+		return strings.Join(names, "\n")
+	}
+
+	// the first scrape only starts the background run
+	if body := scrape(); strings.Contains(body, "test_exporter_collector_last_refresh_timestamp_seconds") {
+		t.Errorf("didn't expect a refresh timestamp before the first background run finished")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		e.options.background.Lock()
+		r := e.options.background.results[e.scrapeKey+"\x00count_keys"]
+		done := r != nil && !r.running
+		e.options.background.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background run of count_keys didn't finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	body := scrape()
+	for _, want := range []string{"test_keys_count", "test_exporter_collector_last_refresh_timestamp_seconds", "test_exporter_collector_success"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+
+	e.options.background.Lock()
+	lastStart := e.options.background.results[e.scrapeKey+"\x00count_keys"].lastStart
+	e.options.background.Unlock()
+	scrape()
+	e.options.background.Lock()
+	if e.options.background.results[e.scrapeKey+"\x00count_keys"].lastStart != lastStart {
+		t.Errorf("didn't expect a new background run before the interval passed")
+	}
+	e.options.background.Unlock()
+
+	e.Stop()
+	if n := len(e.options.background.results); n != 0 {
+		t.Errorf("expected Stop() to remove the results, got %d", n)
+	}
+}
+
+func TestBackgroundResultsRemoved(t *testing.T) {
+	a := exportertest.NewInfoServer(t)
+	b := exportertest.NewInfoServer(t)
+	e, _ := NewRedisExporter("", Options{
+		Namespace:            "test",
+		Registry:             prometheus.NewRegistry(),
+		CountKeys:            "db0=test_*",
+		BackgroundCollectors: map[string]time.Duration{"count_keys": time.Hour},
+		ScrapeCacheSize:      1,
+	})
+	defer e.Stop()
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	owners := func() map[string]bool {
+		e.options.background.Lock()
+		defer e.options.background.Unlock()
+		res := map[string]bool{}
+		for _, r := range e.options.background.results {
+			res[r.owner.redisAddr] = true
+		}
+		return res
+	}
+
+	downloadURL(t, ts.URL+"/scrape?target="+url.QueryEscape(a.URI()))
+	if got := owners(); len(got) != 1 || !got[a.URI()] {
+		t.Fatalf("expected the results of %s, got %v", a.URI(), got)
+	}
+	// evicts the exporter of a from the cache
+	downloadURL(t, ts.URL+"/scrape?target="+url.QueryEscape(b.URI()))
+	if got := owners(); len(got) != 1 || !got[b.URI()] {
+		t.Errorf("expected only the results of %s after the exporter of %s was evicted, got %v", b.URI(), a.URI(), got)
+	}
+
+	// results that aren't used anymore are removed after twice their interval
+	e.options.background.Lock()
+	for _, r := range e.options.background.results {
+		r.running = false
+		r.lastUsed = time.Now().Add(-3 * time.Hour)
+	}
+	e.options.background.removeIdle(time.Now())
+	if n := len(e.options.background.results); n != 0 {
+		t.Errorf("expected the idle results to be removed, got %d", n)
+	}
+	e.options.background.Unlock()
+}
@@ -0,0 +1,238 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Collector collects a group of metrics from a Redis instance.
+// Collectors added with RegisterCollector run after the built-in ones, each one
+// gets the same treatment as those: it can be selected via collect[] query
+// parameters, limited via collector-timeouts and reports its duration and success.
+type Collector interface {
+	// Name identifies the collector, e.g. in the collector label of the exporter_collector_* metrics
+	Name() string
+
+	// Enabled reports whether the collector runs with the given options
+	Enabled(opts *Options) bool
+
+	// Describe sends the descriptions of the metrics the collector exports
+	Describe(ch chan<- *prometheus.Desc)
+
+	// Collect sends the metrics of the instance that c is connected to, the
+	// Instance being scraped is available via InstanceFromContext
+	Collect(ctx context.Context, c redis.Conn, ch chan<- prometheus.Metric) error
+}
+
+// Instance is what the exporter learned about the Redis instance at the start of a scrape
+type Instance struct {
+	Addr    string
+	Info    string // the output of INFO ALL
+	Role    string // InstanceRoleSlave or "master"
+	DBCount int
+}
+
+type instanceCtxKey struct{}
+
+// InstanceFromContext returns the Instance that is being scraped, it's set in the
+// context that is passed to Collector.Collect
+func InstanceFromContext(ctx context.Context) (*Instance, bool) {
+	inst, ok := ctx.Value(instanceCtxKey{}).(*Instance)
+	return inst, ok
+}
+
+var collectorNameRE = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+var (
+	registeredCollectorsMtx sync.RWMutex
+	registeredCollectors    []Collector
+)
+
+// RegisterCollector adds a collector to the collectors of all exporters that are
+// created afterwards. It must be called before the first NewRedisExporter or scrape
+// that uses the collector.
+func RegisterCollector(c Collector) error {
+	name := c.Name()
+	if !collectorNameRE.MatchString(name) {
+		return fmt.Errorf("invalid collector name %q", name)
+	}
+
+	registeredCollectorsMtx.Lock()
+	defer registeredCollectorsMtx.Unlock()
+	if slices.Contains(collectorNames, name) || slices.ContainsFunc(registeredCollectors, func(r Collector) bool { return r.Name() == name }) {
+		return fmt.Errorf("collector %q is already registered", name)
+	}
+	registeredCollectors = append(registeredCollectors, c)
+	return nil
+}
+
+// allCollectorNames returns the names of the built-in and the registered collectors
+func allCollectorNames() []string {
+	registeredCollectorsMtx.RLock()
+	defer registeredCollectorsMtx.RUnlock()
+
+	names := slices.Clone(collectorNames)
+	for _, c := range registeredCollectors {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+// funcCollector is a built-in collector, its metrics are described by the exporter
+type funcCollector struct {
+	name    string
+	enabled func(opts *Options) bool
+	// appliesTo reports whether the collector runs for the scraped instance, nil means it always does
+	appliesTo func(inst *Instance) bool
+	collect   func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error
+	// fatal means an error fails the whole scrape
+	fatal bool
+}
+
+func (f *funcCollector) Name() string { return f.name }
+
+func (f *funcCollector) Enabled(opts *Options) bool { return f.enabled == nil || f.enabled(opts) }
+
+func (f *funcCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (f *funcCollector) Collect(ctx context.Context, c redis.Conn, ch chan<- prometheus.Metric) error {
+	inst, _ := InstanceFromContext(ctx)
+	return f.collect(ctx, inst, c, ch)
+}
+
+// newCollectors returns the collectors of the exporter, the built-in ones first
+func (e *Exporter) newCollectors() []Collector {
+	collectors := []Collector{
+		&funcCollector{
+			name:      "cluster_info",
+			appliesTo: func(inst *Instance) bool { return strings.Contains(inst.Info, "cluster_enabled:1") },
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				clusterInfo, err := redis.String(doRedisCmd(c, "CLUSTER", "INFO"))
+				if err != nil {
+					log.Errorf("Redis CLUSTER INFO err: %s", err)
+					return err
+				}
+				e.extractClusterInfoMetrics(ch, clusterInfo)
+				return nil
+			},
+		},
+		&funcCollector{
+			name: "info",
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				e.extractInfoMetrics(ch, inst.Info, inst.DBCount)
+				return nil
+			},
+		},
+		&funcCollector{
+			name:    "latency",
+			enabled: func(opts *Options) bool { return !opts.ExcludeLatencyHistogramMetrics },
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractLatencyMetrics(ch, inst.Info, c)
+			},
+		},
+		&funcCollector{
+			name:      "check_keys",
+			enabled:   func(opts *Options) bool { return opts.CheckKeys != "" || opts.CheckSingleKeys != "" },
+			appliesTo: e.checkKeysAppliesTo,
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				err := e.extractCheckKeyMetrics(ctx, ch, c)
+				if err != nil {
+					log.Errorf("extractCheckKeyMetrics() err: %s", err)
+				}
+				return err
+			},
+		},
+		&funcCollector{
+			name:      "count_keys",
+			enabled:   func(opts *Options) bool { return opts.CountKeys != "" },
+			appliesTo: e.checkKeysAppliesTo,
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractCountKeysMetrics(ctx, ch, c)
+			},
+		},
+		&funcCollector{
+			name:      "streams",
+			enabled:   func(opts *Options) bool { return opts.CheckStreams != "" || opts.CheckSingleStreams != "" },
+			appliesTo: e.checkKeysAppliesTo,
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractStreamMetrics(ctx, ch, c)
+			},
+		},
+		&funcCollector{
+			name: "slowlog",
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractSlowLogMetrics(ch, c)
+			},
+		},
+		&funcCollector{
+			name:    "key_groups",
+			enabled: func(opts *Options) bool { return strings.TrimSpace(opts.CheckKeyGroups) != "" },
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractKeyGroupMetrics(ctx, ch, c, inst.DBCount)
+			},
+		},
+		&funcCollector{
+			name:      "sentinel",
+			appliesTo: func(inst *Instance) bool { return strings.Contains(inst.Info, "# Sentinel") },
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractSentinelMetrics(ctx, ch, c)
+			},
+		},
+		&funcCollector{
+			name:    "client_list",
+			enabled: func(opts *Options) bool { return opts.ExportClientList },
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractConnectedClientMetrics(ch, c)
+			},
+		},
+		&funcCollector{
+			name:    "tile38",
+			enabled: func(opts *Options) bool { return opts.IsTile38 },
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractTile38Metrics(ch, c)
+			},
+		},
+		&funcCollector{
+			name:    "modules",
+			enabled: func(opts *Options) bool { return opts.InclModulesMetrics },
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				return e.extractModulesMetrics(ch, c)
+			},
+		},
+		&funcCollector{
+			name:    "lua",
+			enabled: func(opts *Options) bool { return len(opts.LuaScript) > 0 },
+			fatal:   true,
+			collect: func(ctx context.Context, inst *Instance, c redis.Conn, ch chan<- prometheus.Metric) error {
+				for filename, script := range e.options.LuaScript {
+					if err := e.extractLuaScriptMetrics(ch, c, filename, script); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	}
+
+	registeredCollectorsMtx.RLock()
+	defer registeredCollectorsMtx.RUnlock()
+	return append(collectors, registeredCollectors...)
+}
+
+// checkKeysAppliesTo skips the key and stream collectors on masters when
+// SkipCheckKeysForRoleMaster is set (can help with reducing workload on the master node)
+func (e *Exporter) checkKeysAppliesTo(inst *Instance) bool {
+	if inst.Role != InstanceRoleSlave && e.options.SkipCheckKeysForRoleMaster {
+		log.Debugf("skipping checkKeys metrics, role: %s  flag: %#v", inst.Role, e.options.SkipCheckKeysForRoleMaster)
+		return false
+	}
+	return true
+}
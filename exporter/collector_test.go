@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
+)
+
+type pingCollector struct {
+	desc *prometheus.Desc
+}
+
+func (p *pingCollector) Name() string { return "in_house" }
+
+func (p *pingCollector) Enabled(opts *Options) bool { return true }
+
+func (p *pingCollector) Describe(ch chan<- *prometheus.Desc) { ch <- p.desc }
+
+func (p *pingCollector) Collect(ctx context.Context, c redis.Conn, ch chan<- prometheus.Metric) error {
+	inst, ok := InstanceFromContext(ctx)
+	if !ok || inst.Info == "" {
+		return nil
+	}
+	if _, err := c.Do("PING"); err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(p.desc, prometheus.GaugeValue, float64(inst.DBCount), inst.Role)
+	return nil
+}
+
+func TestRegisterCollector(t *testing.T) {
+	registeredCollectorsMtx.Lock()
+	prev := registeredCollectors
+	registeredCollectorsMtx.Unlock()
+	t.Cleanup(func() {
+		registeredCollectorsMtx.Lock()
+		registeredCollectors = prev
+		registeredCollectorsMtx.Unlock()
+	})
+
+	col := &pingCollector{desc: prometheus.NewDesc("test_in_house_dbs", "in-house collector", []string{"role"}, nil)}
+	if err := RegisterCollector(col); err != nil {
+		t.Fatalf("RegisterCollector() err: %s", err)
+	}
+	if err := RegisterCollector(col); err == nil {
+		t.Errorf("expected an error when registering a collector twice")
+	}
+	if err := RegisterCollector(&funcCollector{name: "slowlog"}); err == nil {
+		t.Errorf("expected an error when registering a collector with a built-in name")
+	}
+	if err := RegisterCollector(&funcCollector{name: "In-House"}); err == nil {
+		t.Errorf("expected an error for an invalid collector name")
+	}
+
+	if _, err := ParseCollectorTimeouts("in_house=1s"); err != nil {
+		t.Errorf("expected the registered collector to be accepted, err: %s", err)
+	}
+
+	s := exportertest.NewInfoServer(t)
+	e, _ := NewRedisExporter(s.URI(), Options{Namespace: "test"})
+	chM := make(chan prometheus.Metric)
+	go func() {
+		e.Collect(chM)
+		close(chM)
+	}()
+
+	found, success := false, false
+	for m := range chM {
+		desc := m.Desc().String()
+		g := &dto.Metric{}
+		_ = m.Write(g)
+		switch {
+		case strings.Contains(desc, "test_in_house_dbs"):
+			found = true
+		case strings.Contains(desc, "test_exporter_collector_success") && g.GetLabel()[0].GetValue() == "in_house":
+			success = g.GetGauge().GetValue() == 1
+		}
+	}
+	if !found || !success {
+		t.Errorf("expected the registered collector to run, found metric: %t, success: %t", found, success)
+	}
+}
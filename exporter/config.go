@@ -0,0 +1,230 @@
+package exporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of the file passed via --config.file
+type Config struct {
+	// Targets is the list of Redis instances to scrape via /metrics, replaces redis.addr
+	Targets []TargetConfig `yaml:"targets"`
+
+	// Modules are named scrape profiles for the /scrape endpoint
+	Modules map[string]ModuleConfig `yaml:"modules"`
+
+	// Settings maps command line flag names (e.g. "check-keys") to their values
+	Settings map[string]ConfigValue `yaml:",inline"`
+}
+
+// ConfigValue is the value of a single setting in the config file.
+// Lists are joined with commas so comma separated flags like check-keys
+// can be written as a YAML sequence.
+type ConfigValue string
+
+func (v *ConfigValue) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		*v = ConfigValue(n.Value)
+	case yaml.SequenceNode:
+		vals := make([]string, 0, len(n.Content))
+		for _, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: expected a list of scalar values", item.Line)
+			}
+			vals = append(vals, item.Value)
+		}
+		*v = ConfigValue(strings.Join(vals, ","))
+	default:
+		return fmt.Errorf("line %d: expected a scalar value or a list", n.Line)
+	}
+	return nil
+}
+
+// LoadConfigFile reads and strictly parses a YAML (or JSON) config file
+func LoadConfigFile(configFile string) (*Config, error) {
+	log.Debugf("start load config file: %s", configFile)
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("couldn't parse config file %s: %w", configFile, err)
+	}
+
+	log.Infof("Loaded %d settings and %d targets from %s", len(cfg.Settings), len(cfg.Targets), configFile)
+	return cfg, nil
+}
+
+// ConnectionConfig holds the credentials and TLS settings used to connect to Redis
+// that can be set per target or per module in the config file.
+// Settings that are left empty fall back to the global ones.
+type ConnectionConfig struct {
+	User                string `yaml:"user"`
+	Password            string `yaml:"password"`
+	ClientCertFile      string `yaml:"tls-client-cert-file"`
+	ClientKeyFile       string `yaml:"tls-client-key-file"`
+	CaCertFile          string `yaml:"tls-ca-cert-file"`
+	SkipTLSVerification *bool  `yaml:"skip-tls-verification"`
+
+	// AWSIAM authenticates with IAM auth tokens of ElastiCache or MemoryDB instead of the password
+	AWSIAM *AWSIAMConfig `yaml:"aws-iam"`
+}
+
+// validate checks the settings, user is the global user that's used if none is set
+func (c ConnectionConfig) validate(user string) error {
+	if c.User != "" {
+		user = c.User
+	}
+	if c.AWSIAM != nil {
+		return c.AWSIAM.validate(user)
+	}
+	return nil
+}
+
+// TargetConfig is a single Redis instance in the "targets" list of the config file.
+type TargetConfig struct {
+	ConnectionConfig `yaml:",inline"`
+
+	Addr   string            `yaml:"addr"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// ModuleConfig is a named scrape profile in the "modules" section of the config file,
+// selected via /scrape?target=...&module=<name>.
+// Settings that are left out fall back to the global ones.
+type ModuleConfig struct {
+	ConnectionConfig `yaml:",inline"`
+
+	CheckKeys                 ConfigValue `yaml:"check-keys"`
+	CheckSingleKeys           ConfigValue `yaml:"check-single-keys"`
+	CheckKeyGroups            ConfigValue `yaml:"check-key-groups"`
+	CheckStreams              ConfigValue `yaml:"check-streams"`
+	CheckSingleStreams        ConfigValue `yaml:"check-single-streams"`
+	CountKeys                 ConfigValue `yaml:"count-keys"`
+	Script                    ConfigValue `yaml:"script"`
+	CheckKeysBatchSize        *int64      `yaml:"check-keys-batch-size"`
+	MaxDistinctKeyGroups      *int64      `yaml:"max-distinct-key-groups"`
+	ExportClientList          *bool       `yaml:"export-client-list"`
+	ExportClientPort          *bool       `yaml:"export-client-port"`
+	InclConfigMetrics         *bool       `yaml:"include-config-metrics"`
+	InclModulesMetrics        *bool       `yaml:"include-modules-metrics"`
+	InclSystemMetrics         *bool       `yaml:"include-system-metrics"`
+	IsCluster                 *bool       `yaml:"is-cluster"`
+	IsTile38                  *bool       `yaml:"is-tile38"`
+	DisableExportingKeyValues *bool       `yaml:"disable-exporting-key-values"`
+}
+
+// apply overrides the connection settings in opts with the ones that are set
+func (c ConnectionConfig) apply(opts *Options) {
+	if c.User != "" {
+		opts.User = c.User
+	}
+	if c.Password != "" {
+		opts.Password = c.Password
+	}
+	if c.ClientCertFile != "" {
+		opts.ClientCertFile = c.ClientCertFile
+	}
+	if c.ClientKeyFile != "" {
+		opts.ClientKeyFile = c.ClientKeyFile
+	}
+	if c.CaCertFile != "" {
+		opts.CaCertFile = c.CaCertFile
+	}
+	if c.SkipTLSVerification != nil {
+		opts.SkipTLSVerification = *c.SkipTLSVerification
+	}
+}
+
+// applyCredentialProvider sets the credential provider of the connection for opts.User,
+// it must be called once the user is final, e.g. after the one of a /scrape target was set
+func (c ConnectionConfig) applyCredentialProvider(opts *Options) {
+	if c.AWSIAM != nil {
+		opts.CredentialProvider = c.AWSIAM.credentialProvider(opts.User)
+	}
+}
+
+// apply overrides the settings in opts with the ones that are set in the module,
+// scripts holds the already loaded content of the module's Lua scripts
+func (m ModuleConfig) apply(opts *Options, scripts map[string][]byte) {
+	m.ConnectionConfig.apply(opts)
+
+	for _, s := range []struct {
+		val ConfigValue
+		opt *string
+	}{
+		{m.CheckKeys, &opts.CheckKeys},
+		{m.CheckSingleKeys, &opts.CheckSingleKeys},
+		{m.CheckKeyGroups, &opts.CheckKeyGroups},
+		{m.CheckStreams, &opts.CheckStreams},
+		{m.CheckSingleStreams, &opts.CheckSingleStreams},
+		{m.CountKeys, &opts.CountKeys},
+	} {
+		if s.val != "" {
+			*s.opt = string(s.val)
+		}
+	}
+
+	for _, s := range []struct {
+		val *int64
+		opt *int64
+	}{
+		{m.CheckKeysBatchSize, &opts.CheckKeysBatchSize},
+		{m.MaxDistinctKeyGroups, &opts.MaxDistinctKeyGroups},
+	} {
+		if s.val != nil {
+			*s.opt = *s.val
+		}
+	}
+
+	for _, s := range []struct {
+		val *bool
+		opt *bool
+	}{
+		{m.ExportClientList, &opts.ExportClientList},
+		{m.ExportClientPort, &opts.ExportClientsInclPort},
+		{m.InclConfigMetrics, &opts.InclConfigMetrics},
+		{m.InclModulesMetrics, &opts.InclModulesMetrics},
+		{m.InclSystemMetrics, &opts.InclSystemMetrics},
+		{m.IsCluster, &opts.IsCluster},
+		{m.IsTile38, &opts.IsTile38},
+		{m.DisableExportingKeyValues, &opts.DisableExportingKeyValues},
+	} {
+		if s.val != nil {
+			*s.opt = *s.val
+		}
+	}
+
+	if m.Script != "" {
+		opts.LuaScript = scripts
+	}
+}
+
+// loadScripts reads the Lua scripts of the module
+func (m ModuleConfig) loadScripts() (map[string][]byte, error) {
+	if m.Script == "" {
+		return nil, nil
+	}
+	paths := strings.Split(string(m.Script), ",")
+	scripts := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error loading script file %s, err: %w", path, err)
+		}
+		scripts[path] = content
+	}
+	return scripts, nil
+}
@@ -0,0 +1,217 @@
+package exporter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tst := range []struct {
+		name    string
+		content string
+		want    map[string]ConfigValue
+		ok      bool
+	}{
+		{
+			name:    "yaml",
+			content: "redis.addr: redis://localhost:6380\nis-cluster: true\ncheck-keys-batch-size: 500\n",
+			want:    map[string]ConfigValue{"redis.addr": "redis://localhost:6380", "is-cluster": "true", "check-keys-batch-size": "500"},
+			ok:      true,
+		},
+		{
+			name:    "json",
+			content: `{"redis.addr": "redis://localhost:6380", "debug": false}`,
+			want:    map[string]ConfigValue{"redis.addr": "redis://localhost:6380", "debug": "false"},
+			ok:      true,
+		},
+		{
+			name:    "list",
+			content: "check-keys:\n  - db0=user:*\n  - db1=session:*\n",
+			want:    map[string]ConfigValue{"check-keys": "db0=user:*,db1=session:*"},
+			ok:      true,
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    map[string]ConfigValue{},
+			ok:      true,
+		},
+		{
+			name:    "nested-map",
+			content: "redis.addr:\n  host: localhost\n",
+			ok:      false,
+		},
+		{
+			name:    "malformed",
+			content: "redis.addr: [",
+			ok:      false,
+		},
+	} {
+		t.Run(tst.name, func(t *testing.T) {
+			fn := filepath.Join(dir, tst.name+".yaml")
+			if err := os.WriteFile(fn, []byte(tst.content), 0o600); err != nil {
+				t.Fatalf("WriteFile() err: %s", err)
+			}
+			cfg, err := LoadConfigFile(fn)
+			if !tst.ok {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfigFile() err: %s", err)
+			}
+			if len(cfg.Settings) != len(tst.want) {
+				t.Fatalf("got %d settings, want %d: %#v", len(cfg.Settings), len(tst.want), cfg.Settings)
+			}
+			for k, v := range tst.want {
+				if cfg.Settings[k] != v {
+					t.Errorf("setting %s: got %q, want %q", k, cfg.Settings[k], v)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadConfigFile("non-existent.yaml"); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+
+	cfg, err := LoadConfigFile("../contrib/sample-config.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfigFile() err: %s", err)
+	}
+	if cfg.Settings["check-keys"] != "db0=user:*,db1=session:*" {
+		t.Errorf("unexpected check-keys: %s", cfg.Settings["check-keys"])
+	}
+}
+
+func TestConfigReloader(t *testing.T) {
+	calls := 0
+	failing := false
+	e, _ := NewRedisExporter("", Options{Namespace: "test", ConfigReloader: func() error {
+		calls++
+		if failing {
+			return os.ErrNotExist
+		}
+		return nil
+	}})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	for _, tst := range []struct {
+		failing  bool
+		wantCode int
+		wantBody string
+	}{
+		{failing: false, wantCode: http.StatusOK, wantBody: "ok"},
+		{failing: true, wantCode: http.StatusInternalServerError, wantBody: "failed to reload config file"},
+	} {
+		failing = tst.failing
+		resp, err := http.Get(ts.URL + "/-/reload")
+		if err != nil {
+			t.Fatalf("http.Get() err: %s", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("ReadAll() err: %s", err)
+		}
+		if resp.StatusCode != tst.wantCode || !strings.Contains(string(body), tst.wantBody) {
+			t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tst.wantCode, tst.wantBody)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("ConfigReloader called %d times, want 2", calls)
+	}
+}
+
+func TestLoadConfigFileTargetsAndModules(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+namespace: test
+targets:
+  - addr: redis://localhost:6379
+    password: secret
+    labels:
+      env: prod
+modules:
+  sessions-cache:
+    check-keys:
+      - db0=session:*
+    export-client-list: true
+    skip-tls-verification: true
+`
+	if err := os.WriteFile(fn, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() err: %s", err)
+	}
+	cfg, err := LoadConfigFile(fn)
+	if err != nil {
+		t.Fatalf("LoadConfigFile() err: %s", err)
+	}
+	if len(cfg.Settings) != 1 || cfg.Settings["namespace"] != "test" {
+		t.Errorf("unexpected settings: %#v", cfg.Settings)
+	}
+	if len(cfg.Targets) != 1 || cfg.Targets[0].Password != "secret" || cfg.Targets[0].Labels["env"] != "prod" {
+		t.Errorf("unexpected targets: %#v", cfg.Targets)
+	}
+	m, ok := cfg.Modules["sessions-cache"]
+	if !ok {
+		t.Fatalf("module sessions-cache not found: %#v", cfg.Modules)
+	}
+
+	opts := Options{CheckKeys: "db1=foo", CountKeys: "db0=bar*", ExportClientList: false}
+	m.apply(&opts, nil)
+	if opts.CheckKeys != "db0=session:*" || opts.CountKeys != "db0=bar*" || !opts.ExportClientList || !opts.SkipTLSVerification {
+		t.Errorf("unexpected options after applying module: %#v", opts)
+	}
+
+	if err := os.WriteFile(fn, []byte("modules:\n  foo:\n    unknown-setting: 1\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() err: %s", err)
+	}
+	if _, err := LoadConfigFile(fn); err == nil {
+		t.Errorf("expected an error for an unknown module setting")
+	}
+}
+
+func TestScrapeModule(t *testing.T) {
+	e, err := NewRedisExporter("", Options{
+		Namespace: "test",
+		Modules:   map[string]ModuleConfig{"minimal": {}},
+	})
+	if err != nil {
+		t.Fatalf("NewRedisExporter() err: %s", err)
+	}
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	for _, tst := range []struct {
+		module   string
+		wantCode int
+	}{
+		{module: "minimal", wantCode: http.StatusOK},
+		{module: "does-not-exist", wantCode: http.StatusBadRequest},
+	} {
+		resp, err := http.Get(ts.URL + "/scrape?target=redis://127.0.0.1:1&module=" + tst.module)
+		if err != nil {
+			t.Fatalf("http.Get() err: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tst.wantCode {
+			t.Errorf("module %s: got status %d, want %d", tst.module, resp.StatusCode, tst.wantCode)
+		}
+	}
+
+	if _, err := NewRedisExporter("", Options{
+		Namespace: "test",
+		Modules:   map[string]ModuleConfig{"broken": {Script: "does-not-exist.lua"}},
+	}); err == nil {
+		t.Errorf("expected an error for a module with a missing script")
+	}
+}
@@ -0,0 +1,133 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Credentials to authenticate with at a Redis instance
+type Credentials struct {
+	Username  string    `json:"username"`
+	Password  string    `json:"password"`
+	ExpiresAt time.Time `json:"expires_at"` // zero if they don't expire
+}
+
+// CredentialProvider returns the credentials of Redis instances, e.g. from a secret store.
+// The credentials take precedence over the user and password of the options and the password map.
+type CredentialProvider interface {
+	// Credentials returns the credentials of the instance at uri, the uri has no password
+	Credentials(ctx context.Context, uri string) (Credentials, error)
+
+	// Invalidate is called when the instance at uri rejected the credentials,
+	// so the next call of Credentials gets new ones
+	Invalidate(uri string)
+}
+
+// ExecCredentialProvider runs a command with the URI of the instance as the last
+// argument to get its credentials. The command prints them as JSON, e.g.
+// {"username": "exporter", "password": "...", "expires_at": "2024-01-02T15:04:05Z"},
+// the credentials are cached until they expire or Redis rejects them.
+type ExecCredentialProvider struct {
+	command []string
+	timeout time.Duration
+
+	mtx   sync.Mutex
+	cache map[string]Credentials
+	runs  singleflight.Group
+}
+
+// NewExecCredentialProvider returns a provider that runs command, it's killed after timeout
+func NewExecCredentialProvider(command []string, timeout time.Duration) (*ExecCredentialProvider, error) {
+	if len(command) == 0 {
+		return nil, errors.New("no credential command")
+	}
+	return &ExecCredentialProvider{command: command, timeout: timeout, cache: map[string]Credentials{}}, nil
+}
+
+func (p *ExecCredentialProvider) Credentials(ctx context.Context, uri string) (Credentials, error) {
+	p.mtx.Lock()
+	creds, ok := p.cache[uri]
+	p.mtx.Unlock()
+	if ok && (creds.ExpiresAt.IsZero() || time.Now().Before(creds.ExpiresAt)) {
+		return creds, nil
+	}
+
+	// concurrent scrapes of the instance share one run of the command
+	res, err, _ := p.runs.Do(uri, func() (interface{}, error) {
+		creds, err := p.run(ctx, uri)
+		if err != nil {
+			return Credentials{}, err
+		}
+		p.mtx.Lock()
+		p.cache[uri] = creds
+		p.mtx.Unlock()
+		return creds, nil
+	})
+	return res.(Credentials), err
+}
+
+func (p *ExecCredentialProvider) Invalidate(uri string) {
+	p.mtx.Lock()
+	delete(p.cache, uri)
+	p.mtx.Unlock()
+}
+
+func (p *ExecCredentialProvider) run(ctx context.Context, uri string) (Credentials, error) {
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	log.Debugf("Running credential command %s for %s", p.command[0], uri)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command[0], append(p.command[1:], uri)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Credentials{}, fmt.Errorf("credential command %s failed: %w, stderr: %s", p.command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return Credentials{}, fmt.Errorf("couldn't parse the output of credential command %s: %w", p.command[0], err)
+	}
+	return creds, nil
+}
+
+// credentialsURI returns uri without the password for a CredentialProvider
+func credentialsURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.User == nil {
+		return uri
+	}
+	switch _, ok := u.User.Password(); {
+	case ok && u.User.Username() == "":
+		u.User = nil
+	case ok:
+		u.User = url.User(u.User.Username())
+	}
+	return u.String()
+}
+
+// isAuthError returns whether Redis rejected the credentials, the error message is checked
+// because redisc only keeps the messages of the errors of the cluster nodes
+func isAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "WRONGPASS") || strings.Contains(msg, "NOAUTH") ||
+		strings.Contains(msg, "invalid password") || strings.Contains(msg, "invalid username-password")
+}
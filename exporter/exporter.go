@@ -1,6 +1,8 @@
 package exporter
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -15,7 +17,6 @@ import (
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -27,15 +28,18 @@ type BuildInfo struct {
 
 // Exporter implements the prometheus.Exporter interface, and exports Redis metrics.
 type Exporter struct {
-	sync.Mutex
+	sync.RWMutex // guards options.PasswordMap
 
 	redisAddr string
 
 	totalScrapes              prometheus.Counter
 	scrapeDuration            prometheus.Summary
-	targetScrapeRequestErrors prometheus.Counter
+	targetScrapeRequestErrors *prometheus.CounterVec
+	targetScrapeThrottled     *prometheus.CounterVec
 
-	metricDescriptions map[string]*prometheus.Desc
+	metricDescriptions    map[string]*prometheus.Desc
+	metricLabelNames      map[string][]string // label names of the metricDescriptions, used by the MetricFilter
+	metricDescriptionsMtx sync.RWMutex
 
 	options Options
 
@@ -45,6 +49,37 @@ type Exporter struct {
 	mux *http.ServeMux
 
 	buildInfo BuildInfo
+
+	// moduleScripts holds the loaded Lua scripts of the modules, by module name
+	moduleScripts map[string]map[string][]byte
+
+	// targets are the exporters for Options.Targets
+	targets []target
+
+	// ownsSharedState is set when the connection pools and background results were created by this exporter
+	ownsSharedState bool
+
+	// collectors are run by scrapeRedisHost in this order
+	collectors []Collector
+
+	// scrapes de-duplicates overlapping scrapes, see collect()
+	scrapes *scrapeGroup
+
+	// scrapeKey identifies the target and its settings in scrapes
+	scrapeKey string
+
+	// gathers are the contexts for the scrapes of the exporter registered with Options.Registry
+	gathers *gatherContexts
+
+	// scrapeLimiter limits the requests to /scrape, nil if there are no limits
+	scrapeLimiter *scrapeLimiter
+
+	// exporterCache keeps the exporters of /scrape, nil if they aren't cached
+	exporterCache *exporterCache
+
+	// dialURLOnly returns the error of DialURL for redis:// and rediss:// addresses instead of
+	// trying redis.Dial too, which can't connect to them, so the health check gets the actual error
+	dialURLOnly bool
 }
 
 type Options struct {
@@ -83,12 +118,104 @@ type Options struct {
 	RedisMetricsOnly               bool
 	PingOnConnect                  bool
 	RedisPwdFile                   string
-	Registry                       *prometheus.Registry
+	Registry                       *prometheus.Registry // the exporter is registered with it, it's served on MetricsPath
 	BuildInfo                      BuildInfo
 	BasicAuthUsername              string
 	BasicAuthPassword              string
 	SkipCheckKeysForRoleMaster     bool
 	InclMetricsForEmptyDatabases   bool
+
+	// Targets is a list of Redis instances that are all scraped via the metrics endpoint,
+	// the uri passed to NewRedisExporter is not used when this is set
+	Targets []TargetConfig
+
+	// ScrapeTimeoutOffset is subtracted from the scrape timeout sent by Prometheus
+	// in the X-Prometheus-Scrape-Timeout-Seconds header
+	ScrapeTimeoutOffset time.Duration
+
+	// CollectorTimeouts limits how long a collector may run, by collector name
+	CollectorTimeouts map[string]time.Duration
+
+	// Modules are named scrape profiles that can be selected via /scrape?module=<name>
+	Modules map[string]ModuleConfig
+
+	// ConfigReloader is called by the /-/reload endpoint instead of only reloading the pwd file
+	ConfigReloader func() error
+
+	// ConnectionPool keeps authenticated connections open between scrapes instead of
+	// connecting to the target for every scrape
+	ConnectionPool bool
+
+	// ConnectionPoolMaxIdle is the maximum number of idle connections kept per target
+	ConnectionPoolMaxIdle int
+
+	// ConnectionPoolIdleTimeout closes connections that have been idle for longer, 0 keeps them open
+	ConnectionPoolIdleTimeout time.Duration
+
+	// MetricFilter drops series before they are sent, nil sends all of them
+	MetricFilter *MetricFilter
+
+	// BackgroundCollectors runs the collectors in the background at the given interval,
+	// by collector name, scrapes get the result of the last successful run
+	BackgroundCollectors map[string]time.Duration
+
+	// Dump makes the exporter export the metrics of captured command output instead of
+	// scraping a Redis instance, see LoadDump
+	Dump *Dump
+
+	// Recorder records the commands sent to Redis and their replies
+	Recorder *Recorder
+
+	// Replay serves recorded replies instead of connecting to Redis
+	Replay *Replay
+
+	// WebAuth authenticates the requests with the credentials of a web config file,
+	// BasicAuthUsername and BasicAuthPassword are ignored when it's set
+	WebAuth *WebAuth
+
+	// ScrapeTargetAllowlist restricts the targets of the /scrape endpoint, nil allows all targets
+	ScrapeTargetAllowlist *TargetAllowlist
+
+	// ScrapeKnownTargetsOnly restricts the targets of the /scrape endpoint to the ones in the password
+	// file and in Targets, in addition to the ones of ScrapeTargetAllowlist
+	ScrapeKnownTargetsOnly bool
+
+	// ScrapeMaxConcurrent is the maximum number of requests to the /scrape endpoint in flight, 0 is unlimited
+	ScrapeMaxConcurrent int
+
+	// ScrapeOnePerTarget allows one scrape of a target at a time via the /scrape endpoint,
+	// overlapping requests with the same parameters share it
+	ScrapeOnePerTarget bool
+
+	// ScrapeRateLimit is the number of requests per second to the /scrape endpoint, 0 is unlimited,
+	// ScrapeRateBurst requests can be made at once and defaults to the rate
+	ScrapeRateLimit float64
+	ScrapeRateBurst int
+
+	// ScrapeCacheSize is the number of exporters of the /scrape endpoint kept for the next
+	// scrapes of their target, 0 creates a new exporter for every request. The ones that haven't
+	// been used for ScrapeCacheIdleTimeout are removed, 0 keeps them.
+	ScrapeCacheSize        int
+	ScrapeCacheIdleTimeout time.Duration
+
+	// HealthCheckTimeout bounds the checks of /ready and /health?target=, defaults to ConnectionTimeouts
+	HealthCheckTimeout time.Duration
+
+	// CredentialProvider supplies the credentials of the instances, e.g. from a secret store
+	CredentialProvider CredentialProvider
+
+	// RESP3 negotiates RESP3 with HELLO 3 when connecting, the replies are converted
+	// to the RESP2 types so the metrics are the same as with RESP2
+	RESP3 bool
+
+	// pools and background are created by NewRedisExporter when needed and shared
+	// by all the exporters that are created from a copy of the options
+	pools      *connPools
+	background *backgroundResults
+
+	// dialAllowlist is set by the /scrape endpoint for a target that's allowed because of the
+	// addresses its hostname resolves to, the connections are only made to allowed addresses
+	dialAllowlist *TargetAllowlist
 }
 
 // NewRedisExporter returns a new exporter of Redis metrics.
@@ -122,11 +249,17 @@ func NewRedisExporter(uri string, opts Options) (*Exporter, error) {
 			Help:      "Durations of scrapes by the exporter",
 		}),
 
-		targetScrapeRequestErrors: prometheus.NewCounter(prometheus.CounterOpts{
+		targetScrapeRequestErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: opts.Namespace,
 			Name:      "target_scrape_request_errors_total",
 			Help:      "Errors in requests to the exporter",
-		}),
+		}, []string{"reason"}),
+
+		targetScrapeThrottled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "target_scrape_requests_throttled_total",
+			Help:      "Requests to the exporter rejected because of the concurrency and rate limits",
+		}, []string{"reason"}),
 
 		metricMapGauges: map[string]string{
 			// # Server
@@ -435,6 +568,7 @@ func NewRedisExporter(uri string, opts Options) (*Exporter, error) {
 	}
 
 	e.metricDescriptions = map[string]*prometheus.Desc{}
+	e.metricLabelNames = map[string][]string{}
 
 	for k, desc := range map[string]struct {
 		txt  string
@@ -456,6 +590,10 @@ func NewRedisExporter(uri string, opts Options) (*Exporter, error) {
 		"db_keys_cached":                                     {txt: "Total number of cached keys by DB", lbls: []string{"db"}},
 		"db_keys_expiring":                                   {txt: "Total number of expiring keys by DB", lbls: []string{"db"}},
 		"errors_total":                                       {txt: `Total number of errors per error type`, lbls: []string{"err"}},
+		"exporter_collector_duration_seconds":                {txt: "Duration of the last run of a collector in seconds", lbls: []string{"collector"}},
+		"exporter_collector_success":                         {txt: "Whether the last run of a collector succeeded", lbls: []string{"collector"}},
+		"exporter_collector_last_refresh_timestamp_seconds":  {txt: "Unix timestamp of the last successful run of a collector that runs in the background", lbls: []string{"collector"}},
+		"exporter_collector_timeout":                         {txt: "Whether the last run of a collector was cut short because it ran out of time", lbls: []string{"collector"}},
 		"exporter_last_scrape_error":                         {txt: "The last scrape error status.", lbls: []string{"err"}},
 		"key_group_count":                                    {txt: `Count of keys in key group`, lbls: []string{"db", "key_group"}},
 		"key_group_memory_usage_bytes":                       {txt: `Total memory usage of key group in bytes`, lbls: []string{"db", "key_group"}},
@@ -514,19 +652,64 @@ func NewRedisExporter(uri string, opts Options) (*Exporter, error) {
 		"up":                                                 {txt: "Information about the Redis instance"},
 	} {
 		e.metricDescriptions[k] = newMetricDescr(opts.Namespace, k, desc.txt, desc.lbls)
+		e.metricLabelNames[k] = desc.lbls
 	}
 
 	if e.options.MetricsPath == "" {
 		e.options.MetricsPath = "/metrics"
 	}
 
+	e.moduleScripts = map[string]map[string][]byte{}
+	for name, m := range e.options.Modules {
+		if err := m.validate(e.options.User); err != nil {
+			return nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		scripts, err := m.loadScripts()
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		e.moduleScripts[name] = scripts
+	}
+
+	if e.options.ConnectionPool && e.options.pools == nil {
+		e.options.pools = newConnPools(e.options.ConnectionPoolIdleTimeout)
+		e.ownsSharedState = true
+	}
+	if len(e.options.BackgroundCollectors) > 0 && e.options.background == nil {
+		e.options.background = newBackgroundResults()
+		e.ownsSharedState = true
+	}
+
+	// all the reasons are exported from the start
+	for _, reason := range scrapeErrReasons {
+		e.targetScrapeRequestErrors.WithLabelValues(reason)
+	}
+	for _, reason := range scrapeThrottledReasons {
+		e.targetScrapeThrottled.WithLabelValues(reason)
+	}
+	e.scrapeLimiter = newScrapeLimiter(e.options)
+	if e.options.ScrapeCacheSize > 0 {
+		e.exporterCache = newExporterCache(e.options.ScrapeCacheSize, e.options.ScrapeCacheIdleTimeout)
+	}
+
+	e.collectors = e.newCollectors()
+	e.scrapes = newScrapeGroup()
+	e.scrapeKey = e.redisAddr
+	e.gathers = newGatherContexts()
+
 	e.mux = http.NewServeMux()
 
+	if len(e.options.Targets) > 0 {
+		if err := e.createTargets(); err != nil {
+			return nil, err
+		}
+	}
+
 	if e.options.Registry != nil {
-		e.options.Registry.MustRegister(e)
-		e.mux.Handle(e.options.MetricsPath, promhttp.HandlerFor(
-			e.options.Registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError},
-		))
+		if err := e.register(e.options.Registry); err != nil {
+			return nil, err
+		}
+		e.mux.HandleFunc(e.options.MetricsPath, e.metricsHandler)
 
 		if !e.options.RedisMetricsOnly {
 			buildInfoCollector := prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -543,17 +726,46 @@ func NewRedisExporter(uri string, opts Options) (*Exporter, error) {
 	e.mux.HandleFunc("/scrape", e.scrapeHandler)
 	e.mux.HandleFunc("/discover-cluster-nodes", e.discoverClusterNodesHandler)
 	e.mux.HandleFunc("/health", e.healthHandler)
-	e.mux.HandleFunc("/-/reload", e.reloadPwdFile)
+	e.mux.HandleFunc("/ready", e.readyHandler)
+	e.mux.HandleFunc("/-/reload", e.reloadHandler)
 
 	return e, nil
 }
 
+// Stop closes the pooled connections of the exporter and cancels the collectors that
+// run in the background. The exporter must not be used anymore afterwards.
+func (e *Exporter) Stop() {
+	if e.options.background != nil {
+		e.options.background.remove(e)
+	}
+	if !e.ownsSharedState {
+		return
+	}
+	if e.options.pools != nil {
+		e.options.pools.close()
+	}
+	if e.options.background != nil {
+		e.options.background.stop()
+	}
+}
+
 // Describe outputs Redis metric descriptions.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	e.metricDescriptionsMtx.RLock()
+	descs := make([]*prometheus.Desc, 0, len(e.metricDescriptions))
 	for _, desc := range e.metricDescriptions {
+		descs = append(descs, desc)
+	}
+	e.metricDescriptionsMtx.RUnlock()
+
+	for _, desc := range descs {
 		ch <- desc
 	}
 
+	for _, col := range e.collectors {
+		col.Describe(ch)
+	}
+
 	for _, v := range e.metricMapGauges {
 		ch <- newMetricDescr(e.options.Namespace, v, v+" metric", nil)
 	}
@@ -564,35 +776,86 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 
 	ch <- e.totalScrapes.Desc()
 	ch <- e.scrapeDuration.Desc()
-	ch <- e.targetScrapeRequestErrors.Desc()
+	e.targetScrapeRequestErrors.Describe(ch)
+	e.targetScrapeThrottled.Describe(ch)
 }
 
 // Collect fetches new metrics from the RedisHost and updates the appropriate metrics.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.Lock()
-	defer e.Unlock()
-	e.totalScrapes.Inc()
-
-	if e.redisAddr != "" {
-		startTime := time.Now()
-		var up float64
-		if err := e.scrapeRedisHost(ch); err != nil {
-			e.registerConstMetricGauge(ch, "exporter_last_scrape_error", 1.0, fmt.Sprintf("%s", err))
-		} else {
-			up = 1
-			e.registerConstMetricGauge(ch, "exporter_last_scrape_error", 0, "")
-		}
+	ctx, release := e.gathers.context()
+	defer release()
+	e.collect(ctx, ch, nil)
+}
 
-		e.registerConstMetricGauge(ch, "up", up)
+// collect runs a scrape limited to the given set of collectors, nil means all of them, and
+// sends its metrics to ch. Overlapping calls for the same target and collectors share one
+// scrape that runs until the contexts of all of them are done, collectors that are still
+// running then are cut short.
+func (e *Exporter) collect(ctx context.Context, ch chan<- prometheus.Metric, collectors map[string]bool) {
+	metrics, shared, complete := e.scrapes.do(ctx, scrapeGroupKey(e.scrapeKey, collectors), func(ctx context.Context, ch chan<- prometheus.Metric) {
+		e.scrape(ctx, ch, collectors)
+	})
+	if shared {
+		log.Debugf("Shared scrape of %s with other requests", e.redisAddr)
+	}
 
-		took := time.Since(startTime).Seconds()
-		e.scrapeDuration.Observe(took)
-		e.registerConstMetricGauge(ch, "exporter_last_scrape_duration_seconds", took)
+	if complete {
+		for _, m := range metrics {
+			ch <- m
+		}
+	} else {
+		// the shared scrape is still running for other requests, this one gets the
+		// metrics collected before its context was done and is reported as down
+		log.Debugf("Gave up waiting for the shared scrape of %s: %s", e.redisAddr, ctx.Err())
+		status := map[*prometheus.Desc]bool{}
+		for _, name := range []string{"up", "exporter_last_scrape_error", "exporter_last_scrape_duration_seconds"} {
+			status[e.createMetricDescription(name, nil)] = true
+		}
+		for _, m := range metrics {
+			if !status[m.Desc()] {
+				ch <- m
+			}
+		}
+		e.registerConstMetricGauge(ch, "exporter_last_scrape_error", 1.0, fmt.Sprintf("%s", ctx.Err()))
+		e.registerConstMetricGauge(ch, "up", 0)
 	}
 
 	ch <- e.totalScrapes
 	ch <- e.scrapeDuration
-	ch <- e.targetScrapeRequestErrors
+	e.targetScrapeRequestErrors.Collect(ch)
+	e.targetScrapeThrottled.Collect(ch)
+}
+
+// scrape runs a scrape of the Redis instance and sends the collected metrics to ch
+func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric, collectors map[string]bool) {
+	e.totalScrapes.Inc()
+
+	if e.redisAddr == "" && e.options.Dump == nil {
+		return
+	}
+
+	startTime := time.Now()
+	var up float64
+	if err := e.scrapeRedisHost(ctx, ch, collectors); err != nil {
+		e.registerConstMetricGauge(ch, "exporter_last_scrape_error", 1.0, fmt.Sprintf("%s", err))
+	} else {
+		up = 1
+		e.registerConstMetricGauge(ch, "exporter_last_scrape_error", 0, "")
+	}
+
+	e.registerConstMetricGauge(ch, "up", up)
+
+	took := time.Since(startTime).Seconds()
+	e.scrapeDuration.Observe(took)
+	e.registerConstMetricGauge(ch, "exporter_last_scrape_duration_seconds", took)
+}
+
+// redactedConfigKeys are the config settings that contain secrets
+var redactedConfigKeys = map[string]bool{
+	"masterauth":               true,
+	"requirepass":              true,
+	"tls-key-file-pass":        true,
+	"tls-client-key-file-pass": true,
 }
 
 func (e *Exporter) extractConfigMetrics(ch chan<- prometheus.Metric, config []interface{}) (dbCount int, err error) {
@@ -620,12 +883,7 @@ func (e *Exporter) extractConfigMetrics(ch chan<- prometheus.Metric, config []in
 		}
 
 		if e.options.InclConfigMetrics {
-			if redact := map[string]bool{
-				"masterauth":               true,
-				"requirepass":              true,
-				"tls-key-file-pass":        true,
-				"tls-client-key-file-pass": true,
-			}[strKey]; !redact || !e.options.RedactConfigMetrics {
+			if !redactedConfigKeys[strKey] || !e.options.RedactConfigMetrics {
 				e.registerConstMetricGauge(ch, "config_key_value", 1.0, strKey, strVal)
 				if val, err := strconv.ParseFloat(strVal, 64); err == nil {
 					e.registerConstMetricGauge(ch, "config_value", val, strKey)
@@ -664,11 +922,17 @@ func (e *Exporter) extractConfigMetrics(ch chan<- prometheus.Metric, config []in
 	return
 }
 
-func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
+// scrapeRedisHost runs all collectors against the Redis instance,
+// collectors limits the run to the collectors in the set, nil means all of them
+func (e *Exporter) scrapeRedisHost(ctx context.Context, ch chan<- prometheus.Metric, collectors map[string]bool) error {
 	defer log.Debugf("scrapeRedisHost() done")
 
+	if e.options.Dump != nil {
+		return e.scrapeDump(ctx, ch, collectors)
+	}
+
 	startTime := time.Now()
-	c, err := e.connectToRedis()
+	c, err := e.getConn(ctx)
 	connectTookSeconds := time.Since(startTime).Seconds()
 	e.registerConstMetricGauge(ch, "exporter_last_scrape_connect_time_seconds", connectTookSeconds)
 
@@ -684,15 +948,44 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 		log.Debugf("connectToRedis( %s ) err: %s", e.redisAddr, err)
 		return err
 	}
-	defer c.Close()
+	defer func() {
+		c.Close()
+	}()
 
 	log.Debugf("connected to: %s", e.redisAddr)
 	log.Debugf("connecting took %f seconds", connectTookSeconds)
 
+	run := func(name string, collect func(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn) error) error {
+		if collectors != nil && !collectors[name] {
+			log.Debugf("Skipping collector %s", name)
+			return nil
+		}
+		if interval, ok := e.options.BackgroundCollectors[name]; ok {
+			e.runInBackground(ch, name, interval, collect)
+			return nil
+		}
+		return e.runCollector(ctx, ch, name, func(ctx context.Context) error {
+			if c.Err() != nil && ctx.Err() == nil {
+				// the connection is broken, e.g. because the previous collector ran out of time
+				log.Debugf("Reconnecting to %s for collector %s", e.redisAddr, name)
+				newConn, err := e.getConn(ctx)
+				if err != nil {
+					return err
+				}
+				c.Close()
+				c = newConn
+			}
+			return collect(ctx, ch, withContext(ctx, c))
+		})
+	}
+
+	// used for the commands that are not part of a collector
+	sc := withContext(ctx, c)
+
 	if e.options.PingOnConnect {
 		startTime := time.Now()
 
-		if _, err := doRedisCmd(c, "PING"); err != nil {
+		if _, err := doRedisCmd(sc, "PING"); err != nil {
 			log.Errorf("Couldn't PING server, err: %s", err)
 		} else {
 			pingTookSeconds := time.Since(startTime).Seconds()
@@ -701,31 +994,36 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 		}
 	}
 
-	if e.options.SetClientName {
-		if _, err := doRedisCmd(c, "CLIENT", "SETNAME", "redis_exporter"); err != nil {
-			log.Errorf("Couldn't set client name, err: %s", err)
-		}
+	// pooled connections get their name when they are created
+	if e.options.pools == nil {
+		e.setClientName(sc)
 	}
 
 	dbCount := 0
 	if e.options.ConfigCommandName == "-" {
 		log.Debugf("Skipping extractConfigMetrics()")
 	} else {
-		if config, err := redis.Values(doRedisCmd(c, e.options.ConfigCommandName, "GET", "*")); err == nil {
-			dbCount, err = e.extractConfigMetrics(ch, config)
+		var configErr error
+		_ = run("config", func(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn) error {
+			config, err := redis.Values(doRedisCmd(c, e.options.ConfigCommandName, "GET", "*"))
 			if err != nil {
-				log.Errorf("Redis extractConfigMetrics() err: %s", err)
+				log.Debugf("Redis CONFIG err: %s", err)
 				return err
 			}
-		} else {
-			log.Debugf("Redis CONFIG err: %s", err)
+			dbCount, configErr = e.extractConfigMetrics(ch, config)
+			return configErr
+		})
+		if configErr != nil {
+			log.Errorf("Redis extractConfigMetrics() err: %s", configErr)
+			return configErr
 		}
 	}
 
-	infoAll, err := redis.String(doRedisCmd(c, "INFO", "ALL"))
+	// INFO is always fetched, the other collectors depend on it
+	infoAll, err := redis.String(doRedisCmd(sc, "INFO", "ALL"))
 	if err != nil || infoAll == "" {
 		log.Debugf("Redis INFO ALL err: %s", err)
-		infoAll, err = redis.String(doRedisCmd(c, "INFO"))
+		infoAll, err = redis.String(doRedisCmd(sc, "INFO"))
 		if err != nil {
 			log.Errorf("Redis INFO err: %s", err)
 			return err
@@ -733,72 +1031,77 @@ func (e *Exporter) scrapeRedisHost(ch chan<- prometheus.Metric) error {
 	}
 	log.Debugf("Redis INFO ALL result: [%#v]", infoAll)
 
-	if strings.Contains(infoAll, "cluster_enabled:1") {
-		if clusterInfo, err := redis.String(doRedisCmd(c, "CLUSTER", "INFO")); err == nil {
-			e.extractClusterInfoMetrics(ch, clusterInfo)
-
-			// in cluster mode Redis only supports one database, so no extra DB number padding needed
-			dbCount = 1
-		} else {
-			log.Errorf("Redis CLUSTER INFO err: %s", err)
-		}
-	} else if dbCount == 0 {
-		// in non-cluster mode, if dbCount is zero, then "CONFIG" failed to retrieve a valid
-		// number of databases, and we use the Redis config default which is 16
-
-		dbCount = 16
-	}
-
+	dbCount = instanceDBCount(infoAll, dbCount)
 	log.Debugf("dbCount: %d", dbCount)
 
-	role := e.extractInfoMetrics(ch, infoAll, dbCount)
-
-	if !e.options.ExcludeLatencyHistogramMetrics {
-		e.extractLatencyMetrics(ch, infoAll, c)
+	inst := &Instance{
+		Addr:    e.redisAddr,
+		Info:    infoAll,
+		Role:    parseInstanceRole(infoAll),
+		DBCount: dbCount,
 	}
 
-	// skip these metrics for master if SkipCheckKeysForRoleMaster is set
-	// (can help with reducing workload on the master node)
-	log.Debugf("checkKeys metric collection for role: %s  SkipCheckKeysForRoleMaster flag: %#v", role, e.options.SkipCheckKeysForRoleMaster)
-	if role == InstanceRoleSlave || !e.options.SkipCheckKeysForRoleMaster {
-		if err := e.extractCheckKeyMetrics(ch, c); err != nil {
-			log.Errorf("extractCheckKeyMetrics() err: %s", err)
+	for _, col := range e.collectors {
+		if !col.Enabled(&e.options) {
+			continue
+		}
+		fc, builtin := col.(*funcCollector)
+		if builtin && fc.appliesTo != nil && !fc.appliesTo(inst) {
+			continue
+		}
+		err := run(col.Name(), func(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn) error {
+			return col.Collect(context.WithValue(ctx, instanceCtxKey{}, inst), c, ch)
+		})
+		if err != nil && builtin && fc.fatal {
+			return err
 		}
-
-		e.extractCountKeysMetrics(ch, c)
-
-		e.extractStreamMetrics(ch, c)
-	} else {
-		log.Infof("skipping checkKeys metrics, role: %s  flag: %#v", role, e.options.SkipCheckKeysForRoleMaster)
 	}
 
-	e.extractSlowLogMetrics(ch, c)
-
-	e.extractKeyGroupMetrics(ch, c, dbCount)
-
-	if strings.Contains(infoAll, "# Sentinel") {
-		e.extractSentinelMetrics(ch, c)
-	}
+	return nil
+}
 
-	if e.options.ExportClientList {
-		e.extractConnectedClientMetrics(ch, c)
+// instanceDBCount returns the number of databases of the instance, configDBCount
+// is the value of the databases config setting, 0 if it isn't known
+func instanceDBCount(info string, configDBCount int) int {
+	if strings.Contains(info, "cluster_enabled:1") {
+		// in cluster mode Redis only supports one database, so no extra DB number padding needed
+		return 1
 	}
-
-	if e.options.IsTile38 {
-		e.extractTile38Metrics(ch, c)
+	if configDBCount == 0 {
+		// in non-cluster mode, if dbCount is zero, then "CONFIG" failed to retrieve a valid
+		// number of databases, and we use the Redis config default which is 16
+		return 16
 	}
+	return configDBCount
+}
 
-	if e.options.InclModulesMetrics {
-		e.extractModulesMetrics(ch, c)
+// runCollector runs a single stage of a scrape and exports its duration and whether it succeeded.
+// The stage is cut short when it runs out of its time budget or the scrape runs out of time.
+func (e *Exporter) runCollector(ctx context.Context, ch chan<- prometheus.Metric, name string, collect func(ctx context.Context) error) error {
+	if budget := e.options.CollectorTimeouts[name]; budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, budget)
+		defer cancel()
 	}
 
-	if len(e.options.LuaScript) > 0 {
-		for filename, script := range e.options.LuaScript {
-			if err := e.extractLuaScriptMetrics(ch, c, filename, script); err != nil {
-				return err
-			}
+	startTime := time.Now()
+	err := collect(ctx)
+	e.registerConstMetricGauge(ch, "exporter_collector_duration_seconds", time.Since(startTime).Seconds(), name)
+
+	var success, timedOut float64
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		timedOut = 1
+		if err == nil {
+			err = ctx.Err()
 		}
+		log.Warnf("collector %s was cut short, err: %s", name, err)
 	}
-
-	return nil
+	if err == nil {
+		success = 1
+	} else {
+		log.Debugf("collector %s failed, err: %s", name, err)
+	}
+	e.registerConstMetricGauge(ch, "exporter_collector_success", success, name)
+	e.registerConstMetricGauge(ch, "exporter_collector_timeout", timedOut, name)
+	return err
 }
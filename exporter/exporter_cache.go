@@ -0,0 +1,109 @@
+package exporter
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// exporterCache keeps the exporters of the /scrape endpoint so they're reused by the next scrapes
+// of the target with the same parameters. The least recently used exporters are evicted when
+// there are more than size and the ones that haven't been used for idleTimeout.
+type exporterCache struct {
+	sync.Mutex
+	size        int
+	idleTimeout time.Duration // 0 doesn't evict idle exporters
+
+	lru     *list.List // of *cachedExporter, the most recently used first
+	entries map[string]*list.Element
+
+	now func() time.Time // for the tests
+}
+
+type cachedExporter struct {
+	key      string
+	exporter *Exporter
+	lastUsed time.Time
+}
+
+func newExporterCache(size int, idleTimeout time.Duration) *exporterCache {
+	return &exporterCache{
+		size:        size,
+		idleTimeout: idleTimeout,
+		lru:         list.New(),
+		entries:     map[string]*list.Element{},
+		now:         time.Now,
+	}
+}
+
+// get returns the exporter for key, it's created by newExporter if it isn't cached
+func (c *exporterCache) get(key string, newExporter func() (*Exporter, error)) (*Exporter, error) {
+	c.Lock()
+	c.evictIdle()
+	if el, ok := c.entries[key]; ok {
+		ce := el.Value.(*cachedExporter)
+		ce.lastUsed = c.now()
+		c.lru.MoveToFront(el)
+		c.Unlock()
+		return ce.exporter, nil
+	}
+	c.Unlock()
+
+	// exporters are created without holding the lock, they aren't cheap
+	exp, err := newExporter()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if el, ok := c.entries[key]; ok {
+		// created by a concurrent request in the meantime
+		exp.Stop()
+		ce := el.Value.(*cachedExporter)
+		ce.lastUsed = c.now()
+		c.lru.MoveToFront(el)
+		return ce.exporter, nil
+	}
+	c.entries[key] = c.lru.PushFront(&cachedExporter{key: key, exporter: exp, lastUsed: c.now()})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+	return exp, nil
+}
+
+// evictIdle removes the exporters that haven't been used for idleTimeout, c must be locked
+func (c *exporterCache) evictIdle() {
+	if c.idleTimeout <= 0 {
+		return
+	}
+	now := c.now()
+	for el := c.lru.Back(); el != nil && now.Sub(el.Value.(*cachedExporter).lastUsed) > c.idleTimeout; el = c.lru.Back() {
+		c.remove(el)
+	}
+}
+
+func (c *exporterCache) remove(el *list.Element) {
+	ce := c.lru.Remove(el).(*cachedExporter)
+	delete(c.entries, ce.key)
+	log.Debugf("Evicted cached exporter of %s", ce.exporter.redisAddr)
+	ce.exporter.Stop()
+}
+
+// purge removes all exporters, e.g. when the passwords were reloaded
+func (c *exporterCache) purge() {
+	c.Lock()
+	defer c.Unlock()
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// len returns the number of cached exporters
+func (c *exporterCache) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.lru.Len()
+}
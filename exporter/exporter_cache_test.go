@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
+)
+
+func TestExporterCache(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := newExporterCache(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	created := 0
+	get := func(key string) *Exporter {
+		exp, err := c.get(key, func() (*Exporter, error) {
+			created++
+			return &Exporter{redisAddr: key}, nil
+		})
+		if err != nil {
+			t.Fatalf("get(%s) err: %s", key, err)
+		}
+		return exp
+	}
+
+	a := get("a")
+	if get("a") != a || created != 1 {
+		t.Errorf("expected the exporter of a to be reused, created %d", created)
+	}
+	get("b")
+	now = now.Add(30 * time.Second)
+	get("a")
+	get("c") // evicts b, the least recently used one
+	if c.len() != 2 || created != 3 {
+		t.Errorf("expected 2 exporters after creating 3, got %d and created %d", c.len(), created)
+	}
+	if get("a") != a {
+		t.Errorf("expected a to be kept")
+	}
+	get("b")
+	if created != 4 {
+		t.Errorf("expected b to be created again, created %d", created)
+	}
+
+	// b isn't used anymore and is removed once it's idle for longer than a minute
+	now = now.Add(30 * time.Second)
+	get("a")
+	now = now.Add(45 * time.Second)
+	if get("a") != a || c.len() != 1 {
+		t.Errorf("expected b to be evicted as idle, got %d exporters", c.len())
+	}
+	if _, ok := c.entries["b"]; ok {
+		t.Errorf("expected b to be evicted")
+	}
+
+	if _, err := c.get("e", func() (*Exporter, error) { return nil, errors.New("failed") }); err == nil || c.len() != 1 {
+		t.Errorf("expected an error and no new entry, got err: %v, %d entries", err, c.len())
+	}
+
+	c.purge()
+	if c.len() != 0 || len(c.entries) != 0 {
+		t.Errorf("expected an empty cache after purge(), got %d", c.len())
+	}
+}
+
+func TestScrapeHandlerExporterCache(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+
+	pwdFile := filepath.Join(t.TempDir(), "passwords.json")
+	if err := os.WriteFile(pwdFile, []byte(`{"`+s.URI()+`": ""}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := NewRedisExporter("", Options{Namespace: "test", Registry: prometheus.NewRegistry(), RedisPwdFile: pwdFile, ScrapeCacheSize: 10, ScrapeCacheIdleTimeout: time.Minute})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	scrapeURL := ts.URL + "/scrape?target=" + url.QueryEscape(s.URI())
+	scrapesTotal := regexp.MustCompile(`(?m)^test_exporter_scrapes_total(\{.*\})? (\d+)$`)
+	for i, u := range []string{scrapeURL, scrapeURL + "&collect[]=info", scrapeURL} {
+		body := downloadURL(t, u)
+		if m := scrapesTotal.FindStringSubmatch(body); m == nil || m[2] != []string{"1", "2", "3"}[i] {
+			t.Errorf("scrape %d: expected the scrapes to be counted by the cached exporter, got %v in:\n%s", i, m, body)
+		}
+		if strings.Contains(body, "test_exporter_build_info{") != (i != 1) || !strings.Contains(body, "test_up 1") {
+			t.Errorf("scrape %d: expected test_up 1 and the build info without collect[] in:\n%s", i, body)
+		}
+	}
+	if n := e.exporterCache.len(); n != 1 {
+		t.Errorf("expected 1 cached exporter, got %d", n)
+	}
+
+	downloadURL(t, scrapeURL+"&check-keys=db0=key")
+	if n := e.exporterCache.len(); n != 2 {
+		t.Errorf("expected another exporter for other parameters, got %d", n)
+	}
+
+	if body := downloadURL(t, ts.URL+"/-/reload"); body != "ok" {
+		t.Fatalf("expected the password file to be reloaded, got: %s", body)
+	}
+	if n := e.exporterCache.len(); n != 0 {
+		t.Errorf("expected the cache to be cleared when the passwords are reloaded, got %d", n)
+	}
+}
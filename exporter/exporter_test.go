@@ -9,11 +9,14 @@ package exporter
 */
 
 import (
+	"context"
 	"fmt"
 	"github.com/mna/redisc"
+	"net"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -22,6 +25,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	dto "github.com/prometheus/client_model/go"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
 )
 
 const (
@@ -295,7 +300,7 @@ func deleteTestKeys(t *testing.T, addr string) error {
 
 func deleteTestKeysCluster(t *testing.T, addr string) error {
 	e, _ := NewRedisExporter(addr, Options{})
-	c, err := e.connectToRedisCluster()
+	c, err := e.connectToRedisCluster(context.Background())
 	if err != nil {
 		t.Errorf("couldn't setup redis CLUSTER, err: %s ", err)
 		return err
@@ -446,6 +451,52 @@ func TestNonExistingHost(t *testing.T) {
 	}
 }
 
+func TestRunCollector(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{Namespace: "test", CollectorTimeouts: map[string]time.Duration{"slow": 10 * time.Millisecond}})
+
+	chM := make(chan prometheus.Metric)
+	go func() {
+		ctx := context.Background()
+		_ = e.runCollector(ctx, chM, "ok", func(ctx context.Context) error { return nil })
+		if err := e.runCollector(ctx, chM, "failing", func(ctx context.Context) error { return fmt.Errorf("NOPERM") }); err == nil {
+			t.Errorf("expected runCollector() to return the collector's error")
+		}
+		if err := e.runCollector(ctx, chM, "slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}); err == nil {
+			t.Errorf("expected runCollector() to return an error for a collector that ran out of time")
+		}
+		close(chM)
+	}()
+
+	wantSuccess := map[string]float64{"ok": 1, "failing": 0, "slow": 0}
+	wantTimeout := map[string]float64{"ok": 0, "failing": 0, "slow": 1}
+	durations := 0
+	for m := range chM {
+		g := &dto.Metric{}
+		m.Write(g)
+		collector := g.GetLabel()[0].GetValue()
+		switch {
+		case strings.Contains(m.Desc().String(), "test_exporter_collector_success"):
+			if g.GetGauge().GetValue() != wantSuccess[collector] {
+				t.Errorf("collector %s: got success %f, want %f", collector, g.GetGauge().GetValue(), wantSuccess[collector])
+			}
+			delete(wantSuccess, collector)
+		case strings.Contains(m.Desc().String(), "test_exporter_collector_timeout"):
+			if g.GetGauge().GetValue() != wantTimeout[collector] {
+				t.Errorf("collector %s: got timeout %f, want %f", collector, g.GetGauge().GetValue(), wantTimeout[collector])
+			}
+			delete(wantTimeout, collector)
+		case strings.Contains(m.Desc().String(), "test_exporter_collector_duration_seconds"):
+			durations++
+		}
+	}
+	if len(wantSuccess) != 0 || len(wantTimeout) != 0 || durations != 3 {
+		t.Errorf("missing metrics, success not found for: %v, timeout not found for: %v, got %d durations", wantSuccess, wantTimeout, durations)
+	}
+}
+
 func TestKeysReset(t *testing.T) {
 	e, _ := NewRedisExporter(os.Getenv("TEST_REDIS_URI"), Options{Namespace: "test", CheckSingleKeys: dbNumStrFull + "=" + testKeys[0], Registry: prometheus.NewRegistry()})
 	ts := httptest.NewServer(e)
@@ -565,3 +616,97 @@ func init() {
 	AllTestKeys = append(AllTestKeys, testKeysList...)
 	AllTestKeys = append(AllTestKeys, testKeysExpiring...)
 }
+
+func TestOverlappingScrapesAreShared(t *testing.T) {
+	// a server that accepts connections but never replies keeps the scrape in flight
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		connsMtx sync.Mutex
+		conns    []net.Conn
+	)
+	defer func() {
+		l.Close()
+		connsMtx.Lock()
+		defer connsMtx.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			connsMtx.Lock()
+			conns = append(conns, c)
+			connsMtx.Unlock()
+		}
+	}()
+
+	e, _ := NewRedisExporter("redis://"+l.Addr().String(), Options{Namespace: "test", ConnectionTimeouts: 500 * time.Millisecond})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chM := make(chan prometheus.Metric)
+			go func() {
+				e.Collect(chM)
+				close(chM)
+			}()
+			up := false
+			for m := range chM {
+				if strings.Contains(m.Desc().String(), `"test_up"`) {
+					up = true
+				}
+			}
+			if !up {
+				t.Errorf("expected every scrape to get the shared result")
+			}
+		}()
+	}
+	wg.Wait()
+
+	g := &dto.Metric{}
+	_ = e.totalScrapes.Write(g)
+	if got := g.GetCounter().GetValue(); got != 1 {
+		t.Errorf("expected the overlapping scrapes to share one scrape, got %f scrapes", got)
+	}
+}
+
+func TestScrapeFakeServer(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+	s.SetPassword("default", "secret")
+	s.Reply("INFO", "# Server\r\nredis_version:7.2.4\r\nuptime_in_seconds:100\r\n\r\n# Replication\r\nrole:master\r\nconnected_slaves:0\r\n\r\n# Keyspace\r\ndb0:keys=5,expires=1,avg_ttl=100\r\n")
+	s.Reply("CONFIG GET", []string{"databases", "2", "maxmemory", "1024"})
+	s.Reply("SLOWLOG LEN", int64(3))
+	s.Reply("SLOWLOG GET", []interface{}{[]interface{}{int64(7), int64(1700000000), int64(1500)}})
+	s.Reply("LATENCY LATEST", []interface{}{})
+	s.Reply("LATENCY HISTOGRAM", []interface{}{})
+
+	e, _ := NewRedisExporter(s.URI(), Options{Namespace: "test", Password: "secret", SetClientName: true})
+	var buf strings.Builder
+	if up, err := e.WriteMetrics(context.Background(), &buf); err != nil || !up {
+		t.Fatalf("WriteMetrics() up: %t, err: %v\n%s", up, err, buf.String())
+	}
+	for _, want := range []string{
+		"\ntest_uptime_in_seconds 100\n",
+		`test_db_keys{db="db0"} 5`,
+		"\ntest_config_maxmemory 1024\n",
+		"\ntest_slowlog_length 3\n",
+		"\ntest_slowlog_last_id 7\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("didn't find %q in:\n%s", want, buf.String())
+		}
+	}
+
+	if cmds := s.Commands(); len(cmds) < 2 || cmds[0][0] != "AUTH" || strings.Join(cmds[1], " ") != "CLIENT SETNAME redis_exporter" {
+		t.Errorf("unexpected commands: %v", cmds)
+	}
+}
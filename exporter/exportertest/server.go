@@ -0,0 +1,493 @@
+// Package exportertest provides a fake Redis server for testing code that talks to Redis,
+// like the exporter, without running Redis. The server speaks RESP2 and, after HELLO 3,
+// RESP3 and replies to the commands with the replies and handlers registered for them.
+package exportertest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Status is a simple string reply, e.g. Status("OK")
+type Status string
+
+// Error is an error reply, e.g. Error("ERR unknown command")
+type Error string
+
+// KV is an entry of a Map
+type KV struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Map is a map reply with ordered entries, it's sent as a flat array of keys and values to RESP2 clients
+type Map []KV
+
+// Set is a set reply, it's sent as an array to RESP2 clients
+type Set []interface{}
+
+// Handler returns the reply to a command, args[0] is the command name.
+//
+// Replies are encoded by their type: string and []byte as bulk strings, int and int64 as integers,
+// float64 as doubles, bool as booleans, nil as null, []string and []interface{} as arrays,
+// map[string]interface{} as a Map with sorted keys and Status, Error, Map and Set as their type.
+// The RESP3 types are sent like Redis sends them to RESP2 clients if the client didn't send HELLO 3.
+type Handler func(args []string) interface{}
+
+// Server is a fake Redis server that listens on a local TCP port or a unix socket
+type Server struct {
+	// Addr is the address of the server, host:port or the path of the unix socket
+	Addr string
+
+	listener net.Listener
+
+	mtx      sync.Mutex
+	handlers map[string]Handler
+	users    map[string]string // password by user
+	conns    map[net.Conn]bool
+	commands [][]string
+	closed   bool
+
+	wg sync.WaitGroup
+}
+
+// NewServer starts a server on a random port on localhost
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return serve(l), nil
+}
+
+// Info is the reply to INFO of the servers of NewInfoServer, a Redis 7.2 master with 5 keys in db0
+const Info = "# Server\r\nredis_version:7.2.4\r\nuptime_in_seconds:100\r\n\r\n# Replication\r\nrole:master\r\nconnected_slaves:0\r\n\r\n# Keyspace\r\ndb0:keys=5,expires=1,avg_ttl=100\r\n"
+
+// NewInfoServer starts a server like NewServer that replies to INFO with Info,
+// the server is closed when the test is done
+func NewInfoServer(t testing.TB) *Server {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() err: %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.Reply("INFO", Info)
+	return s
+}
+
+// NewUnixServer starts a server that listens on the unix socket at path
+func NewUnixServer(path string) (*Server, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return serve(l), nil
+}
+
+func serve(l net.Listener) *Server {
+	s := &Server{
+		Addr:     l.Addr().String(),
+		listener: l,
+		handlers: map[string]Handler{},
+		conns:    map[net.Conn]bool{},
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s
+}
+
+// URI returns the address of the server as a URI for the exporter, e.g. redis://127.0.0.1:41234
+func (s *Server) URI() string {
+	if s.listener.Addr().Network() == "unix" {
+		return "unix://" + s.Addr
+	}
+	return "redis://" + s.Addr
+}
+
+// Close stops the server and closes all connections
+func (s *Server) Close() error {
+	s.mtx.Lock()
+	s.closed = true
+	err := s.listener.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mtx.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// Handle registers the handler for a command, cmd is the command name, e.g. "INFO",
+// optionally followed by a subcommand, e.g. "CONFIG GET". A handler for a subcommand
+// takes precedence over the one for the command.
+func (s *Server) Handle(cmd string, h Handler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.handlers[strings.ToUpper(cmd)] = h
+}
+
+// Reply registers a canned reply for a command, see Handle
+func (s *Server) Reply(cmd string, reply interface{}) {
+	s.Handle(cmd, func([]string) interface{} { return reply })
+}
+
+// SetPassword requires clients to authenticate with the password of one of the users,
+// user "default" is used for AUTH with a single argument
+func (s *Server) SetPassword(user, password string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.users == nil {
+		s.users = map[string]string{}
+	}
+	s.users[user] = password
+}
+
+// Commands returns the commands the server received, in order
+func (s *Server) Commands() [][]string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([][]string(nil), s.commands...)
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mtx.Lock()
+		if s.closed {
+			s.mtx.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = true
+		s.wg.Add(1)
+		s.mtx.Unlock()
+		go s.serveConn(c)
+	}
+}
+
+// conn is the state of a client connection
+type conn struct {
+	proto         int
+	authenticated bool
+	name          string
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mtx.Lock()
+		delete(s.conns, c)
+		s.mtx.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	st := &conn{proto: 2}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				_ = writeReply(w, Error("ERR Protocol error: "+err.Error()), st.proto)
+				_ = w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		reply := s.reply(st, args)
+		if err := writeReply(w, reply, st.proto); err != nil {
+			return
+		}
+		// flush once all pipelined commands are handled
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if strings.EqualFold(args[0], "QUIT") {
+			_ = w.Flush()
+			return
+		}
+	}
+}
+
+func (s *Server) reply(st *conn, args []string) interface{} {
+	s.mtx.Lock()
+	s.commands = append(s.commands, args)
+	requireAuth := len(s.users) > 0
+	s.mtx.Unlock()
+
+	cmd := strings.ToUpper(args[0])
+	switch cmd {
+	case "AUTH":
+		return s.auth(st, args[1:])
+	case "HELLO":
+		return s.hello(st, args[1:])
+	case "QUIT":
+		return Status("OK")
+	}
+	if requireAuth && !st.authenticated {
+		return Error("NOAUTH Authentication required.")
+	}
+
+	if h := s.handler(args); h != nil {
+		return h(args)
+	}
+	switch cmd {
+	case "PING":
+		if len(args) > 1 {
+			return args[1]
+		}
+		return Status("PONG")
+	case "SELECT":
+		return Status("OK")
+	case "CLIENT":
+		if len(args) > 2 && strings.EqualFold(args[1], "SETNAME") {
+			st.name = args[2]
+			return Status("OK")
+		}
+	}
+	return Error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+}
+
+func (s *Server) handler(args []string) Handler {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if len(args) > 1 {
+		if h, ok := s.handlers[strings.ToUpper(args[0]+" "+args[1])]; ok {
+			return h
+		}
+	}
+	return s.handlers[strings.ToUpper(args[0])]
+}
+
+func (s *Server) auth(st *conn, args []string) interface{} {
+	user, password := "default", ""
+	switch len(args) {
+	case 1:
+		password = args[0]
+	case 2:
+		user, password = args[0], args[1]
+	default:
+		return Error("ERR wrong number of arguments for 'auth' command")
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if len(s.users) == 0 {
+		return Error("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	}
+	if pwd, ok := s.users[user]; !ok || pwd != password {
+		return Error("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+	st.authenticated = true
+	return Status("OK")
+}
+
+// hello handles HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (s *Server) hello(st *conn, args []string) interface{} {
+	proto := st.proto
+	if len(args) > 0 {
+		var err error
+		if proto, err = strconv.Atoi(args[0]); err != nil {
+			return Error("ERR Protocol version is not an integer or out of range")
+		}
+		if proto != 2 && proto != 3 {
+			return Error("NOPROTO unsupported protocol version")
+		}
+		args = args[1:]
+	}
+	for len(args) > 0 {
+		switch {
+		case strings.EqualFold(args[0], "AUTH") && len(args) >= 3:
+			if reply := s.auth(st, args[1:3]); reply != Status("OK") {
+				return reply
+			}
+			args = args[3:]
+		case strings.EqualFold(args[0], "SETNAME") && len(args) >= 2:
+			st.name = args[1]
+			args = args[2:]
+		default:
+			return Error(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[0]))
+		}
+	}
+
+	s.mtx.Lock()
+	requireAuth := len(s.users) > 0
+	s.mtx.Unlock()
+	if requireAuth && !st.authenticated {
+		return Error("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	}
+
+	st.proto = proto
+	return Map{
+		{"server", "redis"},
+		{"version", "7.2.4"},
+		{"proto", int64(proto)},
+		{"id", int64(1)},
+		{"mode", "standalone"},
+		{"role", "master"},
+		{"modules", []interface{}{}},
+	}
+}
+
+// readCommand reads a command, either as a RESP array of bulk strings or inline
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid multibulk length %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected '$', got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeReply(w *bufio.Writer, reply interface{}, proto int) error {
+	_, err := w.Write(appendReply(nil, reply, proto))
+	return err
+}
+
+func appendLine(buf []byte, prefix byte, s string) []byte {
+	return append(append(append(buf, prefix), s...), '\r', '\n')
+}
+
+func appendBulk(buf []byte, s string) []byte {
+	buf = appendLine(buf, '$', strconv.Itoa(len(s)))
+	return append(append(buf, s...), '\r', '\n')
+}
+
+func appendAggregate(buf []byte, prefix byte, items []interface{}, proto int) []byte {
+	buf = appendLine(buf, prefix, strconv.Itoa(len(items)))
+	for _, item := range items {
+		buf = appendReply(buf, item, proto)
+	}
+	return buf
+}
+
+// appendReply encodes the reply like Redis does for a client that speaks protocol version proto
+func appendReply(buf []byte, reply interface{}, proto int) []byte {
+	switch reply := reply.(type) {
+	case Status:
+		return appendLine(buf, '+', string(reply))
+	case Error:
+		return appendLine(buf, '-', string(reply))
+	case string:
+		return appendBulk(buf, reply)
+	case []byte:
+		return appendBulk(buf, string(reply))
+	case int:
+		return appendLine(buf, ':', strconv.Itoa(reply))
+	case int64:
+		return appendLine(buf, ':', strconv.FormatInt(reply, 10))
+	case float64:
+		s := strconv.FormatFloat(reply, 'g', -1, 64)
+		if proto < 3 {
+			return appendBulk(buf, s)
+		}
+		return appendLine(buf, ',', s)
+	case bool:
+		if proto < 3 {
+			if reply {
+				return appendLine(buf, ':', "1")
+			}
+			return appendLine(buf, ':', "0")
+		}
+		if reply {
+			return appendLine(buf, '#', "t")
+		}
+		return appendLine(buf, '#', "f")
+	case nil:
+		if proto < 3 {
+			return append(buf, "$-1\r\n"...)
+		}
+		return append(buf, "_\r\n"...)
+	case []string:
+		items := make([]interface{}, len(reply))
+		for i, s := range reply {
+			items[i] = s
+		}
+		return appendAggregate(buf, '*', items, proto)
+	case []interface{}:
+		return appendAggregate(buf, '*', reply, proto)
+	case Set:
+		if proto < 3 {
+			return appendAggregate(buf, '*', reply, proto)
+		}
+		return appendAggregate(buf, '~', reply, proto)
+	case Map:
+		items := make([]interface{}, 0, 2*len(reply))
+		for _, kv := range reply {
+			items = append(items, kv.Key, kv.Value)
+		}
+		if proto < 3 {
+			return appendAggregate(buf, '*', items, proto)
+		}
+		buf = appendLine(buf, '%', strconv.Itoa(len(reply)))
+		for _, item := range items {
+			buf = appendReply(buf, item, proto)
+		}
+		return buf
+	case map[string]interface{}:
+		keys := make([]string, 0, len(reply))
+		for k := range reply {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		m := make(Map, len(keys))
+		for i, k := range keys {
+			m[i] = KV{k, reply[k]}
+		}
+		return appendReply(buf, m, proto)
+	default:
+		return appendLine(buf, '-', fmt.Sprintf("ERR exportertest: unsupported reply type %T", reply))
+	}
+}
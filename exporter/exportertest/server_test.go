@@ -0,0 +1,158 @@
+package exportertest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer() err: %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestServerReplies(t *testing.T) {
+	s := newTestServer(t)
+	s.Reply("INFO", "# Server\r\nredis_version:7.2.4\r\n")
+	s.Reply("CONFIG GET", []string{"maxmemory", "0"})
+	s.Reply("CONFIG", Error("ERR unknown subcommand"))
+	s.Handle("ECHO", func(args []string) interface{} { return strings.Join(args[1:], " ") })
+	s.Reply("XINFO STREAM", Map{{"length", int64(2)}, {"groups", int64(1)}})
+
+	c, err := redis.DialURL(s.URI())
+	if err != nil {
+		t.Fatalf("DialURL() err: %s", err)
+	}
+	defer c.Close()
+
+	if info, err := redis.String(c.Do("INFO", "ALL")); err != nil || !strings.Contains(info, "redis_version:7.2.4") {
+		t.Errorf("INFO: %q, err: %v", info, err)
+	}
+	if config, err := redis.Strings(c.Do("CONFIG", "GET", "*")); err != nil || !reflect.DeepEqual(config, []string{"maxmemory", "0"}) {
+		t.Errorf("CONFIG GET: %v, err: %v", config, err)
+	}
+	if _, err := c.Do("CONFIG", "RESETSTAT"); err == nil || err.Error() != "ERR unknown subcommand" {
+		t.Errorf("CONFIG RESETSTAT: expected the error reply, got: %v", err)
+	}
+	if echo, err := redis.String(c.Do("ECHO", "a", "b")); err != nil || echo != "a b" {
+		t.Errorf("ECHO: %q, err: %v", echo, err)
+	}
+	if stream, err := redis.Values(c.Do("XINFO", "STREAM", "events")); err != nil || len(stream) != 4 {
+		t.Errorf("XINFO STREAM: expected a flat array for RESP2, got: %v, err: %v", stream, err)
+	}
+	if _, err := c.Do("GET", "key"); err == nil {
+		t.Errorf("expected an error for an unknown command")
+	}
+
+	// pipelined commands
+	for i := 0; i < 3; i++ {
+		if err := c.Send("PING"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if pong, err := redis.String(c.Receive()); err != nil || pong != "PONG" {
+			t.Errorf("PING: %q, err: %v", pong, err)
+		}
+	}
+
+	if cmds := s.Commands(); len(cmds) != 9 || cmds[0][0] != "INFO" {
+		t.Errorf("unexpected commands: %v", cmds)
+	}
+}
+
+func TestServerAuth(t *testing.T) {
+	s := newTestServer(t)
+	s.SetPassword("default", "secret")
+	s.SetPassword("exporter", "pwd")
+
+	if _, err := redis.DialURL(s.URI(), redis.DialPassword("wrong")); err == nil || !strings.HasPrefix(err.Error(), "WRONGPASS") {
+		t.Errorf("expected WRONGPASS, got: %v", err)
+	}
+	for _, opts := range [][]redis.DialOption{
+		{redis.DialPassword("secret")},
+		{redis.DialUsername("exporter"), redis.DialPassword("pwd")},
+	} {
+		c, err := redis.DialURL(s.URI(), opts...)
+		if err != nil {
+			t.Fatalf("DialURL() err: %s", err)
+		}
+		if _, err := c.Do("PING"); err != nil {
+			t.Errorf("PING err: %s", err)
+		}
+		c.Close()
+	}
+
+	c, err := redis.DialURL(s.URI())
+	if err != nil {
+		t.Fatalf("DialURL() err: %s", err)
+	}
+	defer c.Close()
+	if _, err := c.Do("PING"); err == nil || !strings.HasPrefix(err.Error(), "NOAUTH") {
+		t.Errorf("expected NOAUTH, got: %v", err)
+	}
+}
+
+func TestServerRESP3(t *testing.T) {
+	s, err := NewUnixServer(filepath.Join(t.TempDir(), "redis.sock"))
+	if err != nil {
+		t.Fatalf("NewUnixServer() err: %s", err)
+	}
+	defer s.Close()
+	s.Reply("MEMORY STATS", Map{{"peak.allocated", int64(1024)}, {"fragmentation", 1.5}})
+	s.Reply("SMEMBERS", Set{"a"})
+	s.Reply("EXISTS", true)
+	s.Reply("GET", nil)
+	s.SetPassword("exporter", "pwd")
+
+	c, err := net.Dial("unix", s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	r := bufio.NewReader(c)
+
+	for _, tst := range []struct {
+		cmd  string
+		want string
+	}{
+		{cmd: "MEMORY STATS", want: "-NOAUTH"},
+		{cmd: "HELLO 3 AUTH exporter pwd SETNAME redis_exporter", want: "%7\r\n$6\r\nserver\r\n"},
+		{cmd: "MEMORY STATS", want: "%2\r\n$14\r\npeak.allocated\r\n:1024\r\n$13\r\nfragmentation\r\n,1.5\r\n"},
+		{cmd: "SMEMBERS s", want: "~1\r\n$1\r\na\r\n"},
+		{cmd: "EXISTS k", want: "#t\r\n"},
+		{cmd: "GET k", want: "_\r\n"},
+		{cmd: "HELLO 2", want: "*14\r\n"},
+		{cmd: "MEMORY STATS", want: "*4\r\n$14\r\npeak.allocated\r\n:1024\r\n$13\r\nfragmentation\r\n$3\r\n1.5\r\n"},
+	} {
+		// inline commands like redis-cli sends them
+		if _, err := c.Write([]byte(tst.cmd + "\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(tst.want))
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf); !strings.HasPrefix(got, tst.want) {
+			t.Errorf("%s: got %q, want %q", tst.cmd, got, tst.want)
+		}
+		// skip the rest of the reply
+		for r.Buffered() > 0 {
+			_, _ = r.Discard(r.Buffered())
+		}
+	}
+}
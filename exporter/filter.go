@@ -0,0 +1,146 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MetricFilter drops series before they are sent, so they are never serialized.
+// Metric names are matched including the namespace, e.g. "redis_commands_latencies_usec".
+type MetricFilter struct {
+	// Allow keeps only the metrics whose name matches, nil keeps all of them
+	Allow *regexp.Regexp
+	// Deny drops the metrics whose name matches
+	Deny *regexp.Regexp
+	// Labels drops the series with a label value that doesn't match,
+	// a matcher only applies to the metrics that have the label
+	Labels []LabelMatcher
+}
+
+// LabelMatcher matches the value of a label like a Prometheus label matcher
+type LabelMatcher struct {
+	Name  string
+	Op    string // one of =, !=, =~, !~
+	Value string
+	re    *regexp.Regexp
+}
+
+var labelMatcherRE = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*`)
+
+// NewMetricFilter returns the filter for the given metric name allowlist and denylist
+// regexes and the comma separated label matchers, e.g. `cmd!~"config\\|.*",db="db0"`.
+// It returns nil if there is nothing to filter.
+func NewMetricFilter(allow, deny, labelFilters string) (*MetricFilter, error) {
+	if allow == "" && deny == "" && strings.TrimSpace(labelFilters) == "" {
+		return nil, nil
+	}
+
+	f := &MetricFilter{}
+	var err error
+	if allow != "" {
+		if f.Allow, err = anchoredRegexp(allow); err != nil {
+			return nil, fmt.Errorf("invalid metric allowlist: %w", err)
+		}
+	}
+	if deny != "" {
+		if f.Deny, err = anchoredRegexp(deny); err != nil {
+			return nil, fmt.Errorf("invalid metric denylist: %w", err)
+		}
+	}
+	if f.Labels, err = parseLabelMatchers(labelFilters); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// anchoredRegexp compiles expr so it has to match the whole value, like Prometheus does
+func anchoredRegexp(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+func parseLabelMatchers(s string) ([]LabelMatcher, error) {
+	var matchers []LabelMatcher
+	for rest := strings.TrimSpace(s); rest != ""; {
+		m := labelMatcherRE.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("invalid label filter %q, expected <label><op>\"<value>\"", rest)
+		}
+		matcher := LabelMatcher{Name: m[1], Op: m[2]}
+		rest = rest[len(m[0]):]
+
+		if strings.HasPrefix(rest, `"`) {
+			end := 1
+			for ; end < len(rest) && rest[end] != '"'; end++ {
+				if rest[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(rest) {
+				return nil, fmt.Errorf("label filter for %s: missing closing quote", matcher.Name)
+			}
+			val, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("label filter for %s: %w", matcher.Name, err)
+			}
+			matcher.Value = val
+			rest = rest[end+1:]
+		} else {
+			val, after, _ := strings.Cut(rest, ",")
+			matcher.Value = strings.TrimSpace(val)
+			rest = "," + after
+		}
+
+		if matcher.Op == "=~" || matcher.Op == "!~" {
+			re, err := anchoredRegexp(matcher.Value)
+			if err != nil {
+				return nil, fmt.Errorf("label filter for %s: %w", matcher.Name, err)
+			}
+			matcher.re = re
+		}
+		matchers = append(matchers, matcher)
+
+		rest = strings.TrimSpace(rest)
+		if rest != "" && !strings.HasPrefix(rest, ",") {
+			return nil, fmt.Errorf("invalid label filter %q, expected a comma", rest)
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	}
+	return matchers, nil
+}
+
+func (m LabelMatcher) matches(val string) bool {
+	switch m.Op {
+	case "=":
+		return val == m.Value
+	case "!=":
+		return val != m.Value
+	case "=~":
+		return m.re.MatchString(val)
+	default:
+		return !m.re.MatchString(val)
+	}
+}
+
+// keep reports whether the series of the metric with the given name, label names
+// and label values passes the filter
+func (f *MetricFilter) keep(name string, labelNames []string, labelValues []string) bool {
+	if f == nil {
+		return true
+	}
+	if f.Allow != nil && !f.Allow.MatchString(name) {
+		return false
+	}
+	if f.Deny != nil && f.Deny.MatchString(name) {
+		return false
+	}
+	for _, m := range f.Labels {
+		for i, l := range labelNames {
+			if l == m.Name && i < len(labelValues) && !m.matches(labelValues[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewMetricFilter(t *testing.T) {
+	if f, err := NewMetricFilter("", "", ""); f != nil || err != nil {
+		t.Errorf("expected no filter, got: %v, err: %v", f, err)
+	}
+
+	f, err := NewMetricFilter("", "redis_connected_client_.*", `cmd!~"config\\|.*", db = db0, role!="slave"`)
+	if err != nil {
+		t.Fatalf("NewMetricFilter() err: %s", err)
+	}
+	if len(f.Labels) != 3 || f.Labels[0].Value != `config\|.*` || f.Labels[1].Value != "db0" || f.Labels[2].Op != "!=" {
+		t.Errorf("unexpected label matchers: %#v", f.Labels)
+	}
+
+	for _, tst := range []struct {
+		name        string
+		labelNames  []string
+		labelValues []string
+		want        bool
+	}{
+		{name: "redis_up", want: true},
+		{name: "redis_connected_client_info", want: false},
+		{name: "redis_commands_latencies_usec", labelNames: []string{"cmd"}, labelValues: []string{"get"}, want: true},
+		{name: "redis_commands_latencies_usec", labelNames: []string{"cmd"}, labelValues: []string{"config|get"}, want: false},
+		{name: "redis_db_keys", labelNames: []string{"db"}, labelValues: []string{"db1"}, want: false},
+		{name: "redis_instance_info", labelNames: []string{"role"}, labelValues: []string{"slave"}, want: false},
+	} {
+		if got := f.keep(tst.name, tst.labelNames, tst.labelValues); got != tst.want {
+			t.Errorf("keep(%s, %v) = %t, want %t", tst.name, tst.labelValues, got, tst.want)
+		}
+	}
+
+	allow, _ := NewMetricFilter("redis_up|redis_memory_.*", "", "")
+	if !allow.keep("redis_memory_used_bytes", nil, nil) || allow.keep("redis_uptime_in_seconds", nil, nil) || allow.keep("xredis_up", nil, nil) {
+		t.Errorf("expected the allowlist to match whole metric names")
+	}
+
+	for _, invalid := range [][3]string{{"(", "", ""}, {"", "[", ""}, {"", "", `cmd~"x"`}, {"", "", `cmd="x`}, {"", "", `cmd=~"("`}, {"", "", `cmd="a" db="b"`}} {
+		if _, err := NewMetricFilter(invalid[0], invalid[1], invalid[2]); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestMetricFilterDropsSeries(t *testing.T) {
+	f, _ := NewMetricFilter("", "test_slowlog_.*", `cmd!~"config\\|.*"`)
+	e, _ := NewRedisExporter("", Options{Namespace: "test", MetricFilter: f})
+	e.createMetricDescription("commands_total", []string{"cmd"})
+
+	chM := make(chan prometheus.Metric)
+	go func() {
+		e.registerConstMetricGauge(chM, "slowlog_length", 1)
+		e.registerConstMetric(chM, "commands_total", 1, prometheus.CounterValue, "config|get")
+		e.registerConstMetric(chM, "commands_total", 1, prometheus.CounterValue, "get")
+		e.registerConstMetricGauge(chM, "up", 1)
+		close(chM)
+	}()
+
+	var got []string
+	for m := range chM {
+		got = append(got, m.Desc().String())
+	}
+	if len(got) != 2 || !strings.Contains(got[0], "test_commands_total") || !strings.Contains(got[1], "test_up") {
+		t.Errorf("unexpected metrics after filtering: %v", got)
+	}
+}
@@ -0,0 +1,238 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+// the error classes of a failed health check
+const (
+	healthErrTimeout    = "timeout"
+	healthErrDNS        = "dns"
+	healthErrConnection = "connection"
+	healthErrTLS        = "tls"
+	healthErrAuth       = "auth"
+	healthErrRedis      = "redis" // an error reply, e.g. LOADING
+	healthErrUnknown    = "unknown"
+)
+
+// healthStatus is the result of the health check of a Redis instance
+type healthStatus struct {
+	Status         string  `json:"status"` // "ok" or "error"
+	Target         string  `json:"target"`
+	LatencySeconds float64 `json:"latency_seconds"`
+	Role           string  `json:"role,omitempty"`
+	Error          string  `json:"error,omitempty"`
+	ErrorClass     string  `json:"error_class,omitempty"`
+}
+
+// readiness is the response of /ready
+type readiness struct {
+	Status  string         `json:"status"`
+	Targets []healthStatus `json:"targets"`
+}
+
+// healthHandler is the liveness check of the exporter, with the target parameter
+// it checks if the target is reachable like readyHandler
+func (e *Exporter) healthHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		_, _ = w.Write([]byte(`ok`))
+		return
+	}
+
+	if !strings.Contains(target, "://") {
+		target = "redis://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid 'target' parameter, parse err: %s", err), http.StatusBadRequest)
+		return
+	}
+	allowed, dialAllowlist, err := e.isTargetAllowed(r.Context(), u)
+	if !allowed {
+		if err != nil {
+			log.Errorf("Couldn't check if target %s is allowed, err: %s", targetInstance(target), err)
+		}
+		http.Error(w, fmt.Sprintf("Target %s is not allowed", targetInstance(target)), http.StatusForbidden)
+		return
+	}
+
+	release, ok := e.limitScrape(w, target, "")
+	if !ok {
+		return
+	}
+	defer release()
+
+	e.RLock()
+	opts := e.options
+	e.RUnlock()
+	opts.dialAllowlist = dialAllowlist
+	if u.User != nil {
+		opts.User = u.User.Username()
+		u.User = nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), e.healthCheckTimeout())
+	defer cancel()
+	status := checkHealth(ctx, u.String(), opts)
+	writeHealthResponse(w, status.Status == "ok", status)
+}
+
+// readyHandler is the readiness check of the exporter, it checks if the instance of
+// the exporter or all the targets of the config file are reachable
+func (e *Exporter) readyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), e.healthCheckTimeout())
+	defer cancel()
+
+	var exporters []*Exporter
+	switch {
+	case e.options.Dump != nil || e.options.Replay != nil:
+		// there's no Redis instance to check
+	case len(e.targets) > 0:
+		for _, t := range e.targets {
+			exporters = append(exporters, t.exporter)
+		}
+	case e.redisAddr != "":
+		exporters = append(exporters, e)
+	}
+
+	res := readiness{Status: "ok", Targets: make([]healthStatus, len(exporters))}
+	var wg sync.WaitGroup
+	for i, exp := range exporters {
+		wg.Add(1)
+		go func(i int, exp *Exporter) {
+			defer wg.Done()
+			exp.RLock()
+			opts := exp.options
+			exp.RUnlock()
+			res.Targets[i] = checkHealth(ctx, exp.redisAddr, opts)
+		}(i, exp)
+	}
+	wg.Wait()
+
+	for _, t := range res.Targets {
+		if t.Status != "ok" {
+			res.Status = "error"
+		}
+	}
+	writeHealthResponse(w, res.Status == "ok", res)
+}
+
+func (e *Exporter) healthCheckTimeout() time.Duration {
+	if e.options.HealthCheckTimeout > 0 {
+		return e.options.HealthCheckTimeout
+	}
+	if e.options.ConnectionTimeouts > 0 {
+		return e.options.ConnectionTimeouts
+	}
+	return 15 * time.Second
+}
+
+func writeHealthResponse(w http.ResponseWriter, ok bool, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Couldn't write health check response, err: %s", err)
+	}
+}
+
+// checkHealth connects (and authenticates) to the instance at addr on a new connection and sends
+// a PING and a ROLE. It returns when ctx is done even if Redis doesn't reply.
+func checkHealth(ctx context.Context, addr string, opts Options) healthStatus {
+	deadline, ok := ctx.Deadline()
+	if ok {
+		opts.ConnectionTimeouts = time.Until(deadline)
+	}
+	probe := &Exporter{redisAddr: addr, options: opts, dialURLOnly: true}
+
+	type result struct {
+		role string
+		err  error
+	}
+	start := time.Now()
+	done := make(chan result, 1)
+	go func() {
+		c, err := probe.connectToRedis(ctx)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer c.Close()
+		c = withContext(ctx, c)
+
+		if _, err := redis.String(doRedisCmd(c, "PING")); err != nil {
+			done <- result{err: err}
+			return
+		}
+		// ROLE can be denied by the ACLs, it doesn't fail the check
+		var role string
+		if reply, err := redis.Values(doRedisCmd(c, "ROLE")); err == nil && len(reply) > 0 {
+			role, _ = redis.String(reply[0], nil)
+		}
+		done <- result{role: role}
+	}()
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+
+	status := healthStatus{Status: "ok", Target: targetInstance(addr), LatencySeconds: time.Since(start).Seconds(), Role: res.role}
+	if res.err != nil {
+		log.Debugf("Health check of %s failed, err: %s", status.Target, res.err)
+		status.Status = "error"
+		status.Error = res.err.Error()
+		status.ErrorClass = healthErrorClass(res.err)
+	}
+	return status
+}
+
+// healthErrorClass returns the class of the error of a health check
+func healthErrorClass(err error) string {
+	var (
+		netErr     net.Error
+		dnsErr     *net.DNSError
+		opErr      *net.OpError
+		redisErr   redis.Error
+		certErr    *tls.CertificateVerificationError
+		unknownCA  x509.UnknownAuthorityError
+		hostErr    x509.HostnameError
+		recordErr  tls.RecordHeaderError
+		alertErr   tls.AlertError
+		invalidErr x509.CertificateInvalidError
+	)
+	switch {
+	case isAuthError(err):
+		return healthErrAuth
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return healthErrTimeout
+	case errors.As(err, &dnsErr):
+		return healthErrDNS
+	case errors.As(err, &certErr), errors.As(err, &unknownCA), errors.As(err, &hostErr),
+		errors.As(err, &recordErr), errors.As(err, &alertErr), errors.As(err, &invalidErr):
+		return healthErrTLS
+	case errors.As(err, &opErr):
+		return healthErrConnection
+	case errors.As(err, &redisErr):
+		return healthErrRedis
+	}
+	return healthErrUnknown
+}
@@ -0,0 +1,193 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
+)
+
+func TestHealthErrorClass(t *testing.T) {
+	for _, tst := range []struct {
+		err  error
+		want string
+	}{
+		{err: redis.Error("WRONGPASS invalid username-password pair or user is disabled."), want: healthErrAuth},
+		{err: redis.Error("NOAUTH Authentication required."), want: healthErrAuth},
+		{err: fmt.Errorf("couldn't negotiate RESP3: %w", redis.Error("WRONGPASS invalid password")), want: healthErrAuth},
+		{err: context.DeadlineExceeded, want: healthErrTimeout},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "i/o timeout", Name: "redis", IsTimeout: true}}, want: healthErrTimeout},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "redis", IsNotFound: true}}, want: healthErrDNS},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")}, want: healthErrConnection},
+		{err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{Cert: &x509.Certificate{}}}, want: healthErrTLS},
+		{err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "redis"}, want: healthErrTLS},
+		{err: redis.Error("LOADING Redis is loading the dataset in memory"), want: healthErrRedis},
+		{err: errors.New("something else"), want: healthErrUnknown},
+	} {
+		if got := healthErrorClass(tst.err); got != tst.want {
+			t.Errorf("%v: got %q, want %q", tst.err, got, tst.want)
+		}
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+	s.SetPassword("default", "secret")
+	s.Reply("ROLE", []interface{}{"master", int64(0), []interface{}{}})
+
+	loading := exportertest.NewInfoServer(t)
+	loading.Reply("PING", exportertest.Error("LOADING Redis is loading the dataset in memory"))
+
+	hanging := exportertest.NewInfoServer(t)
+	unblock := make(chan struct{})
+	defer close(unblock)
+	hanging.Handle("PING", func([]string) interface{} {
+		<-unblock
+		return exportertest.Status("PONG")
+	})
+
+	// nothing listens on the address of a closed listener
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := l.Addr().String()
+	l.Close()
+
+	for _, tst := range []struct {
+		name       string
+		addr       string
+		opts       Options
+		wantCode   int
+		wantStatus []healthStatus
+	}{
+		{
+			name:       "ok",
+			addr:       s.URI(),
+			opts:       Options{Password: "secret"},
+			wantCode:   http.StatusOK,
+			wantStatus: []healthStatus{{Status: "ok", Target: s.URI(), Role: "master"}},
+		},
+		{
+			name:       "wrong password",
+			addr:       s.URI(),
+			opts:       Options{Password: "wrong"},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: []healthStatus{{Status: "error", Target: s.URI(), ErrorClass: healthErrAuth}},
+		},
+		{
+			name:       "connection refused",
+			addr:       "redis://" + closedAddr,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: []healthStatus{{Status: "error", Target: "redis://" + closedAddr, ErrorClass: healthErrConnection}},
+		},
+		{
+			name:       "loading",
+			addr:       loading.URI(),
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: []healthStatus{{Status: "error", Target: loading.URI(), ErrorClass: healthErrRedis}},
+		},
+		{
+			name:       "timeout",
+			addr:       hanging.URI(),
+			opts:       Options{HealthCheckTimeout: 200 * time.Millisecond},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: []healthStatus{{Status: "error", Target: hanging.URI(), ErrorClass: healthErrTimeout}},
+		},
+		{
+			name:     "targets",
+			opts:     Options{Password: "secret", Targets: []TargetConfig{{Addr: s.URI()}, {Addr: loading.URI()}}},
+			wantCode: http.StatusServiceUnavailable,
+			wantStatus: []healthStatus{
+				{Status: "ok", Target: s.URI(), Role: "master"},
+				{Status: "error", Target: loading.URI(), ErrorClass: healthErrRedis},
+			},
+		},
+		{
+			name:     "scrape endpoint only",
+			wantCode: http.StatusOK,
+		},
+	} {
+		t.Run(tst.name, func(t *testing.T) {
+			tst.opts.Namespace = "test"
+			tst.opts.Registry = prometheus.NewRegistry()
+			e, err := NewRedisExporter(tst.addr, tst.opts)
+			if err != nil {
+				t.Fatalf("NewRedisExporter() err: %s", err)
+			}
+			ts := httptest.NewServer(e)
+			defer ts.Close()
+
+			start := time.Now()
+			code, body := downloadURLWithStatusCode(t, ts.URL+"/ready")
+			if time.Since(start) > 2*time.Second {
+				t.Errorf("the check took %s", time.Since(start))
+			}
+			if code != tst.wantCode {
+				t.Errorf("got %d, want %d: %s", code, tst.wantCode, body)
+			}
+
+			var res readiness
+			if err := json.Unmarshal([]byte(body), &res); err != nil {
+				t.Fatalf("couldn't parse %s, err: %s", body, err)
+			}
+			if wantStatus := map[bool]string{true: "ok", false: "error"}[tst.wantCode == http.StatusOK]; res.Status != wantStatus {
+				t.Errorf("got status %q, want %q", res.Status, wantStatus)
+			}
+			if len(res.Targets) != len(tst.wantStatus) {
+				t.Fatalf("got %d targets, want %d: %s", len(res.Targets), len(tst.wantStatus), body)
+			}
+			for i, want := range tst.wantStatus {
+				got := res.Targets[i]
+				if got.Status != want.Status || got.Target != want.Target || got.Role != want.Role || got.ErrorClass != want.ErrorClass {
+					t.Errorf("got %+v, want %+v", got, want)
+				}
+				if (got.Error != "") != (want.Status == "error") || got.LatencySeconds <= 0 {
+					t.Errorf("expected an error message for failed checks and the latency, got %+v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthHandlerTarget(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+	s.SetPassword("exporter", "secret")
+	s.Reply("ROLE", []interface{}{"slave", "10.0.0.1", int64(6379), "connected", int64(100)})
+
+	target := "redis://exporter@" + s.Addr
+	allowlist, _ := NewTargetAllowlist([]string{"127.0.0.0/8"})
+	e, _ := NewRedisExporter("", Options{Namespace: "test", Registry: prometheus.NewRegistry(), ScrapeTargetAllowlist: allowlist, PasswordMap: map[string]string{target: "secret"}})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	if code, body := downloadURLWithStatusCode(t, ts.URL+"/health"); code != http.StatusOK || body != "ok" {
+		t.Errorf("expected /health to stay a liveness check, got %d: %s", code, body)
+	}
+
+	code, body := downloadURLWithStatusCode(t, ts.URL+"/health?target="+url.QueryEscape(target))
+	var status healthStatus
+	if err := json.Unmarshal([]byte(body), &status); err != nil {
+		t.Fatalf("couldn't parse %s, err: %s", body, err)
+	}
+	if code != http.StatusOK || status.Status != "ok" || status.Role != "slave" || status.Target != s.URI() {
+		t.Errorf("got %d: %s", code, body)
+	}
+	if cmds := s.Commands(); len(cmds) == 0 || fmt.Sprint(cmds[0]) != "[AUTH exporter secret]" {
+		t.Errorf("expected the check to authenticate as the user of the target, got: %v", cmds)
+	}
+
+	if code, body := downloadURLWithStatusCode(t, ts.URL+"/health?target=10.0.0.1:6379"); code != http.StatusForbidden {
+		t.Errorf("expected a target that isn't allowed to be rejected, got %d: %s", code, body)
+	}
+}
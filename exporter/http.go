@@ -1,12 +1,15 @@
 package exporter
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,8 +17,29 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// the reasons of target_scrape_request_errors_total
+const (
+	scrapeErrMissingTarget     = "missing_target"
+	scrapeErrInvalidTarget     = "invalid_target"
+	scrapeErrInvalidParameters = "invalid_parameters"
+	scrapeErrUnknownModule     = "unknown_module"
+	scrapeErrTargetNotAllowed  = "target_not_allowed"
+	scrapeErrExporter          = "exporter_error"
+)
+
+var scrapeErrReasons = []string{
+	scrapeErrMissingTarget, scrapeErrInvalidTarget, scrapeErrInvalidParameters,
+	scrapeErrUnknownModule, scrapeErrTargetNotAllowed, scrapeErrExporter,
+}
+
 func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if err := e.verifyBasicAuth(r.BasicAuth()); err != nil {
+	if e.options.WebAuth != nil {
+		if challenge, err := e.options.WebAuth.authenticate(r, e.webEndpoint(r.URL.Path)); err != nil {
+			w.Header().Set("WWW-Authenticate", challenge)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	} else if err := e.verifyBasicAuth(r.BasicAuth()); err != nil {
 		w.Header().Set("WWW-Authenticate", `Basic realm="redis-exporter, charset=UTF-8"`)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
@@ -24,10 +48,6 @@ func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	e.mux.ServeHTTP(w, r)
 }
 
-func (e *Exporter) healthHandler(w http.ResponseWriter, r *http.Request) {
-	_, _ = w.Write([]byte(`ok`))
-}
-
 func (e *Exporter) indexHandler(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(`<html>
 <head><title>Redis Exporter ` + e.buildInfo.Version + `</title></head>
@@ -39,11 +59,41 @@ func (e *Exporter) indexHandler(w http.ResponseWriter, r *http.Request) {
 `))
 }
 
+func (e *Exporter) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	collectors, err := parseCollectParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel, err := e.scrapeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	e.serveMetrics(ctx, w, r, collectors)
+}
+
+// serveMetrics serves the metrics of a scrape of the exporter within ctx, see gatherer()
+func (e *Exporter) serveMetrics(ctx context.Context, w http.ResponseWriter, r *http.Request, collectors map[string]bool) {
+	gatherer, done, err := e.gatherer(ctx, collectors)
+	if err != nil {
+		log.Errorf("Error registering scrape, err: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer done()
+
+	promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}).ServeHTTP(w, r)
+}
+
 func (e *Exporter) scrapeHandler(w http.ResponseWriter, r *http.Request) {
 	target := r.URL.Query().Get("target")
 	if target == "" {
 		http.Error(w, "'target' parameter must be specified", http.StatusBadRequest)
-		e.targetScrapeRequestErrors.Inc()
+		e.targetScrapeRequestErrors.WithLabelValues(scrapeErrMissingTarget).Inc()
 		return
 	}
 
@@ -54,11 +104,48 @@ func (e *Exporter) scrapeHandler(w http.ResponseWriter, r *http.Request) {
 	u, err := url.Parse(target)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Invalid 'target' parameter, parse err: %ck ", err), http.StatusBadRequest)
-		e.targetScrapeRequestErrors.Inc()
+		e.targetScrapeRequestErrors.WithLabelValues(scrapeErrInvalidTarget).Inc()
+		return
+	}
+
+	allowed, dialAllowlist, err := e.isTargetAllowed(r.Context(), u)
+	if !allowed {
+		if err != nil {
+			log.Errorf("Couldn't check if target %s is allowed, err: %s", targetInstance(target), err)
+		}
+		http.Error(w, fmt.Sprintf("Target %s is not allowed", targetInstance(target)), http.StatusForbidden)
+		e.targetScrapeRequestErrors.WithLabelValues(scrapeErrTargetNotAllowed).Inc()
 		return
 	}
 
-	opts := e.options
+	collectors, err := parseCollectParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		e.targetScrapeRequestErrors.WithLabelValues(scrapeErrInvalidParameters).Inc()
+		return
+	}
+
+	ctx, cancel, err := e.scrapeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		e.targetScrapeRequestErrors.WithLabelValues(scrapeErrInvalidParameters).Inc()
+		return
+	}
+	defer cancel()
+
+	opts := e.childOptions()
+	opts.dialAllowlist = dialAllowlist
+
+	var m ModuleConfig
+	if module := r.URL.Query().Get("module"); module != "" {
+		var ok bool
+		if m, ok = e.options.Modules[module]; !ok {
+			http.Error(w, fmt.Sprintf("Unknown module %q", module), http.StatusBadRequest)
+			e.targetScrapeRequestErrors.WithLabelValues(scrapeErrUnknownModule).Inc()
+			return
+		}
+		m.apply(&opts, e.moduleScripts[module])
+	}
 
 	// get rid of username/password info in "target" so users don't send them in plain text via http
 	// and save "user" in options so we can use it later when connecting to the redis instance
@@ -68,6 +155,7 @@ func (e *Exporter) scrapeHandler(w http.ResponseWriter, r *http.Request) {
 		u.User = nil
 	}
 	target = u.String()
+	m.applyCredentialProvider(&opts)
 
 	if ck := r.URL.Query().Get("check-keys"); ck != "" {
 		opts.CheckKeys = ck
@@ -89,19 +177,58 @@ func (e *Exporter) scrapeHandler(w http.ResponseWriter, r *http.Request) {
 		opts.CountKeys = cntk
 	}
 
-	registry := prometheus.NewRegistry()
-	opts.Registry = registry
+	// all the parameters but collect[] change the options of the exporter
+	params := r.URL.Query()
+	params.Del("collect[]")
 
-	_, err = NewRedisExporter(target, opts)
+	release, ok := e.limitScrape(w, target, scrapeGroupKey(params.Encode(), collectors))
+	if !ok {
+		return
+	}
+	defer release()
+	newExporter := func() (*Exporter, error) {
+		opts.Registry = prometheus.NewRegistry()
+		exp, err := NewRedisExporter(target, opts)
+		if err != nil {
+			return nil, err
+		}
+		// overlapping requests for the same target with the same parameters share one scrape
+		exp.scrapes = e.scrapes
+		exp.scrapeKey = params.Encode()
+		return exp, nil
+	}
+
+	var exp *Exporter
+	if e.exporterCache != nil {
+		exp, err = e.exporterCache.get(params.Encode(), newExporter)
+	} else {
+		exp, err = newExporter()
+	}
 	if err != nil {
 		http.Error(w, "NewRedisExporter() err: err", http.StatusBadRequest)
-		e.targetScrapeRequestErrors.Inc()
+		e.targetScrapeRequestErrors.WithLabelValues(scrapeErrExporter).Inc()
 		return
 	}
 
-	promhttp.HandlerFor(
-		registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError},
-	).ServeHTTP(w, r)
+	exp.serveMetrics(ctx, w, r, collectors)
+}
+
+// limitScrape reserves a request to target from the limiter of the /scrape endpoint, key is the key of
+// its shared scrape, see scrapeGroupKey. If the request is over a limit it writes the response and
+// returns false, otherwise release must be called when the request is done.
+func (e *Exporter) limitScrape(w http.ResponseWriter, target, key string) (release func(), ok bool) {
+	if e.scrapeLimiter == nil {
+		return func() {}, true
+	}
+	release, reason, retryAfter := e.scrapeLimiter.acquire(targetKey(target), key)
+	if release == nil {
+		log.Debugf("Rejected request for %s, reason: %s", targetInstance(target), reason)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, fmt.Sprintf("Too many scrape requests (%s)", reason), http.StatusTooManyRequests)
+		e.targetScrapeThrottled.WithLabelValues(reason).Inc()
+		return nil, false
+	}
+	return release, true
 }
 
 func (e *Exporter) discoverClusterNodesHandler(w http.ResponseWriter, r *http.Request) {
@@ -110,7 +237,7 @@ func (e *Exporter) discoverClusterNodesHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	c, err := e.connectToRedisCluster()
+	c, err := e.connectToRedisCluster(r.Context())
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Couldn't connect to redis cluster: %s", err), http.StatusInternalServerError)
 		return
@@ -152,6 +279,20 @@ func (e *Exporter) discoverClusterNodesHandler(w http.ResponseWriter, r *http.Re
 	_, _ = w.Write(data)
 }
 
+func (e *Exporter) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if e.options.ConfigReloader != nil {
+		log.Debugf("Reload config file")
+		if err := e.options.ConfigReloader(); err != nil {
+			log.Errorf("Error reloading config file, err: %s", err)
+			http.Error(w, "failed to reload config file: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`ok`))
+		return
+	}
+	e.reloadPwdFile(w, r)
+}
+
 func (e *Exporter) reloadPwdFile(w http.ResponseWriter, r *http.Request) {
 	if e.options.RedisPwdFile == "" {
 		http.Error(w, "There is no pwd file specified", http.StatusBadRequest)
@@ -167,6 +308,15 @@ func (e *Exporter) reloadPwdFile(w http.ResponseWriter, r *http.Request) {
 	e.Lock()
 	e.options.PasswordMap = passwordMap
 	e.Unlock()
+	for _, t := range e.targets {
+		t.exporter.Lock()
+		t.exporter.options.PasswordMap = passwordMap
+		t.exporter.Unlock()
+	}
+	if e.exporterCache != nil {
+		// the cached exporters have the previous passwords
+		e.exporterCache.purge()
+	}
 	_, _ = w.Write([]byte(`ok`))
 }
 
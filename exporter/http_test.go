@@ -12,9 +12,12 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
 )
 
 func TestHTTPScrapeMetricsEndpoints(t *testing.T) {
@@ -698,6 +701,95 @@ func TestBasicAuth(t *testing.T) {
 	}
 }
 
+func TestCollectParams(t *testing.T) {
+	e, _ := NewRedisExporter(os.Getenv("TEST_REDIS_URI"), Options{Namespace: "test", Registry: prometheus.NewRegistry()})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	for _, path := range []string{"/metrics", "/scrape?target=localhost:6379&"} {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = ""
+		}
+		if code, _ := downloadURLWithStatusCode(t, ts.URL+path+sep+"collect[]=does-not-exist"); code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d for an unknown collector, want %d", path, code, http.StatusBadRequest)
+		}
+	}
+
+	if os.Getenv("TEST_REDIS_URI") == "" {
+		t.Skipf("Skipping the rest of TestCollectParams, missing env var TEST_REDIS_URI")
+	}
+
+	body := downloadURL(t, ts.URL+"/metrics?collect[]=slowlog&collect[]=config")
+	for _, want := range []string{
+		`test_exporter_collector_success{collector="slowlog"} 1`,
+		`test_exporter_collector_success{collector="config"} 1`,
+		`test_up 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want metrics to include %s, have:\n%s", want, body)
+		}
+	}
+	for _, notWant := range []string{`collector="info"`, `test_connected_clients`} {
+		if strings.Contains(body, notWant) {
+			t.Errorf("did NOT want metrics to include %s, have:\n%s", notWant, body)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+	unblock := make(chan struct{})
+	defer close(unblock)
+	s.Handle("INFO", func([]string) interface{} {
+		select {
+		case <-unblock:
+		case <-time.After(time.Second):
+		}
+		return "# Server\r\nredis_version:7.2.4\r\n"
+	})
+
+	registry := prometheus.NewRegistry()
+	e, err := NewRedisExporter(s.URI(), Options{Namespace: "test", Registry: registry})
+	if err != nil {
+		t.Fatalf("NewRedisExporter() err: %s", err)
+	}
+
+	// the exporter is registered with the registry for users of the package that serve it themselves
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() err: %s", err)
+	}
+	found := false
+	for _, mf := range mfs {
+		if mf.GetName() == "test_up" && mf.GetMetric()[0].GetGauge().GetValue() == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected test_up 1 in the gathered metrics")
+	}
+
+	// and /metrics still cuts the scrape short at the scrape timeout of Prometheus
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/metrics", nil)
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "0.6")
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.Do() err: %s", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if took := time.Since(start); took > 900*time.Millisecond {
+		t.Errorf("expected the scrape to be cut short after 100ms, took %s", took)
+	}
+	if !strings.Contains(string(body), "test_up 0") || !strings.Contains(string(body), "test_exporter_build_info{") {
+		t.Errorf("expected test_up 0 and the build info, got:\n%s", body)
+	}
+}
+
 func downloadURL(t *testing.T, u string) string {
 	_, res := downloadURLWithStatusCode(t, u)
 	return res
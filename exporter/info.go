@@ -206,6 +206,16 @@ func (e *Exporter) extractInfoMetrics(ch chan<- prometheus.Metric, info string,
 	return instanceRole
 }
 
+// parseInstanceRole returns the role (master or slave) from the output of INFO
+func parseInstanceRole(info string) string {
+	for _, line := range strings.Split(info, "\n") {
+		if role, ok := strings.CutPrefix(strings.TrimSpace(line), "role:"); ok {
+			return role
+		}
+	}
+	return ""
+}
+
 func (e *Exporter) generateCommandLatencySummaries(ch chan<- prometheus.Metric, cmdLatencyMap map[string]map[float64]float64, cmdCount map[string]uint64, cmdSum map[string]float64) {
 	for cmd, latencyMap := range cmdLatencyMap {
 		count, okCount := cmdCount[cmd]
@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"sort"
@@ -30,10 +31,10 @@ type keyGroupsScrapeResult struct {
 	overflowedMetrics []*overflowedKeyGroupMetrics
 }
 
-func (e *Exporter) extractKeyGroupMetrics(ch chan<- prometheus.Metric, c redis.Conn, dbCount int) {
-	allDbKeyGroupMetrics := e.gatherKeyGroupsMetricsForAllDatabases(c, dbCount)
+func (e *Exporter) extractKeyGroupMetrics(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn, dbCount int) error {
+	allDbKeyGroupMetrics, err := e.gatherKeyGroupsMetricsForAllDatabases(ctx, c, dbCount)
 	if allDbKeyGroupMetrics == nil {
-		return
+		return err
 	}
 	for db, dbKeyGroupMetrics := range allDbKeyGroupMetrics.metrics {
 		dbLabel := fmt.Sprintf("db%d", db)
@@ -68,9 +69,10 @@ func (e *Exporter) extractKeyGroupMetrics(ch chan<- prometheus.Metric, c redis.C
 		}
 	}
 	e.registerConstMetricGauge(ch, "last_key_groups_scrape_duration_milliseconds", float64(allDbKeyGroupMetrics.duration.Milliseconds()))
+	return err
 }
 
-func (e *Exporter) gatherKeyGroupsMetricsForAllDatabases(c redis.Conn, dbCount int) *keyGroupsScrapeResult {
+func (e *Exporter) gatherKeyGroupsMetricsForAllDatabases(ctx context.Context, c redis.Conn, dbCount int) (*keyGroupsScrapeResult, error) {
 	start := time.Now()
 	allMetrics := &keyGroupsScrapeResult{
 		metrics:           make([]map[string]*keyGroupMetrics, dbCount),
@@ -80,14 +82,14 @@ func (e *Exporter) gatherKeyGroupsMetricsForAllDatabases(c redis.Conn, dbCount i
 		allMetrics.duration = time.Since(start)
 	}()
 	if strings.TrimSpace(e.options.CheckKeyGroups) == "" {
-		return allMetrics
+		return allMetrics, nil
 	}
 	keyGroups, err := csv.NewReader(
 		strings.NewReader(e.options.CheckKeyGroups),
 	).Read()
 	if err != nil {
 		log.Errorf("Failed to parse key groups as csv: %s", err)
-		return allMetrics
+		return allMetrics, err
 	}
 	for i, v := range keyGroups {
 		keyGroups[i] = strings.TrimSpace(v)
@@ -100,16 +102,22 @@ func (e *Exporter) gatherKeyGroupsMetricsForAllDatabases(c redis.Conn, dbCount i
 		}
 	}
 	if len(keyGroupsNoEmptyStrings) == 0 {
-		return allMetrics
+		return allMetrics, nil
 	}
+	var lastErr error
 	for db := 0; db < dbCount; db++ {
+		if err := ctx.Err(); err != nil {
+			return allMetrics, err
+		}
 		if _, err := doRedisCmd(c, "SELECT", db); err != nil {
 			log.Errorf("Couldn't select database %d when getting key info.", db)
+			lastErr = err
 			continue
 		}
 		allGroups, err := gatherKeyGroupMetrics(c, e.options.CheckKeysBatchSize, keyGroupsNoEmptyStrings)
 		if err != nil {
 			log.Error(err)
+			lastErr = err
 			continue
 		}
 		allMetrics.metrics[db] = allGroups
@@ -143,7 +151,7 @@ func (e *Exporter) gatherKeyGroupsMetricsForAllDatabases(c redis.Conn, dbCount i
 			}
 		}
 	}
-	return allMetrics
+	return allMetrics, lastErr
 }
 
 func gatherKeyGroupMetrics(c redis.Conn, batchSize int64, keyGroups []string) (map[string]*keyGroupMetrics, error) {
@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"os"
 	"reflect"
 	"strconv"
@@ -114,7 +115,7 @@ func TestKeyGroupMetrics(t *testing.T) {
 			for {
 				chM := make(chan prometheus.Metric)
 				go func() {
-					e.extractKeyGroupMetrics(chM, c, dbCount)
+					e.extractKeyGroupMetrics(context.Background(), chM, c, dbCount)
 					close(chM)
 				}()
 
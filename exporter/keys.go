@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -83,17 +84,17 @@ func (e *Exporter) getKeyInfo(ch chan<- prometheus.Metric, c redis.Conn, dbLabel
 	}
 }
 
-func (e *Exporter) extractCheckKeyMetrics(ch chan<- prometheus.Metric, redisClient redis.Conn) error {
+func (e *Exporter) extractCheckKeyMetrics(ctx context.Context, ch chan<- prometheus.Metric, redisClient redis.Conn) error {
 	c := redisClient
 
 	if e.options.IsCluster {
-		cc, err := e.connectToRedisCluster()
+		cc, err := e.connectToRedisCluster(ctx)
 		if err != nil {
 			return fmt.Errorf("couldn't connect to redis cluster, err: %s", err)
 		}
 		defer cc.Close()
 
-		c = cc
+		c = withContext(ctx, cc)
 	}
 
 	keys, err := parseKeyArg(e.options.CheckKeys)
@@ -112,10 +113,11 @@ func (e *Exporter) extractCheckKeyMetrics(ch chan<- prometheus.Metric, redisClie
 
 	log.Debugf("e.keys: %#v", keys)
 
-	if scannedKeys, err := getKeysFromPatterns(c, keys, e.options.CheckKeysBatchSize); err == nil {
+	scannedKeys, scanErr := getKeysFromPatterns(c, keys, e.options.CheckKeysBatchSize)
+	if scanErr == nil {
 		allKeys = append(allKeys, scannedKeys...)
 	} else {
-		log.Errorf("Error expanding key patterns: %#v", err)
+		log.Errorf("Error expanding key patterns: %#v", scanErr)
 	}
 
 	log.Debugf("allKeys: %#v", allKeys)
@@ -125,14 +127,14 @@ func (e *Exporter) extractCheckKeyMetrics(ch chan<- prometheus.Metric, redisClie
 		(pipelined/non-pipelined) need to be modified
 	*/
 	if e.options.IsCluster {
-		e.extractCheckKeyMetricsNotPipelined(ch, c, allKeys)
+		e.extractCheckKeyMetricsNotPipelined(ctx, ch, c, allKeys)
 	} else {
-		e.extractCheckKeyMetricsPipelined(ch, c, allKeys)
+		e.extractCheckKeyMetricsPipelined(ctx, ch, c, allKeys)
 	}
-	return nil
+	return scanErr
 }
 
-func (e *Exporter) extractCheckKeyMetricsPipelined(ch chan<- prometheus.Metric, c redis.Conn, allKeys []dbKeyPair) {
+func (e *Exporter) extractCheckKeyMetricsPipelined(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn, allKeys []dbKeyPair) {
 	//
 	// the following commands are all pipelined/batched to improve performance
 	// by removing one roundtrip to the redis instance
@@ -156,6 +158,9 @@ func (e *Exporter) extractCheckKeyMetricsPipelined(ch chan<- prometheus.Metric,
 	}
 
 	for dbNum, arrayOfKeys := range keysByDb {
+		if ctx.Err() != nil {
+			return
+		}
 		dbLabel := "db" + dbNum
 
 		log.Debugf("c.Send() SELECT [%s]", dbNum)
@@ -359,10 +364,13 @@ func (e *Exporter) getKeyInfoPipelined(ch chan<- prometheus.Metric, c redis.Conn
 	}
 }
 
-func (e *Exporter) extractCheckKeyMetricsNotPipelined(ch chan<- prometheus.Metric, c redis.Conn, allKeys []dbKeyPair) {
+func (e *Exporter) extractCheckKeyMetricsNotPipelined(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn, allKeys []dbKeyPair) {
 	// Cluster mode only has one db
 	// no need to run `SELECT" but got to set it to "0" in the loop because it's used as the label
 	for _, k := range allKeys {
+		if ctx.Err() != nil {
+			return
+		}
 		k.db = "0"
 
 		keyType, err := redis.String(doRedisCmd(c, "TYPE", k.key))
@@ -382,26 +390,33 @@ func (e *Exporter) extractCheckKeyMetricsNotPipelined(ch chan<- prometheus.Metri
 	}
 }
 
-func (e *Exporter) extractCountKeysMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+func (e *Exporter) extractCountKeysMetrics(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn) error {
 	cntKeys, err := parseKeyArg(e.options.CountKeys)
 	if err != nil {
 		log.Errorf("Couldn't parse given count keys: %s", err)
-		return
+		return err
 	}
 
+	var lastErr error
 	for _, k := range cntKeys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if _, err := doRedisCmd(c, "SELECT", k.db); err != nil {
 			log.Errorf("Couldn't select database '%s' when getting stream info", k.db)
+			lastErr = err
 			continue
 		}
 		cnt, err := getKeysCount(c, k.key, e.options.CheckKeysBatchSize)
 		if err != nil {
 			log.Errorf("couldn't get key count for '%s', err: %s", k.key, err)
+			lastErr = err
 			continue
 		}
 		dbLabel := "db" + k.db
 		e.registerConstMetricGauge(ch, "keys_count", float64(cnt), dbLabel, k.key)
 	}
+	return lastErr
 }
 
 func getKeysCount(c redis.Conn, pattern string, count int64) (int, error) {
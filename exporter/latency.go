@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"errors"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,12 +19,14 @@ var (
 	extractUsecRegexp = regexp.MustCompile(`(?m)^cmdstat_([a-zA-Z0-9\|]+):.*usec=([0-9]+).*$`)
 )
 
-func (e *Exporter) extractLatencyMetrics(ch chan<- prometheus.Metric, infoAll string, c redis.Conn) {
-	e.extractLatencyLatestMetrics(ch, c)
-	e.extractLatencyHistogramMetrics(ch, infoAll, c)
+func (e *Exporter) extractLatencyMetrics(ch chan<- prometheus.Metric, infoAll string, c redis.Conn) error {
+	return errors.Join(
+		e.extractLatencyLatestMetrics(ch, c),
+		e.extractLatencyHistogramMetrics(ch, infoAll, c),
+	)
 }
 
-func (e *Exporter) extractLatencyLatestMetrics(outChan chan<- prometheus.Metric, redisConn redis.Conn) {
+func (e *Exporter) extractLatencyLatestMetrics(outChan chan<- prometheus.Metric, redisConn redis.Conn) error {
 	reply, err := redis.Values(doRedisCmd(redisConn, "LATENCY", "LATEST"))
 	if err != nil {
 		/*
@@ -34,7 +37,7 @@ func (e *Exporter) extractLatencyLatestMetrics(outChan chan<- prometheus.Metric,
 			log.Errorf("WARNING, LOGGED ONCE ONLY: cmd LATENCY LATEST, err: %s", err)
 		})
 		log.Debugf("cmd LATENCY LATEST, err: %s", err)
-		return
+		return err
 	}
 
 	for _, l := range reply {
@@ -48,19 +51,20 @@ func (e *Exporter) extractLatencyLatestMetrics(outChan chan<- prometheus.Metric,
 			}
 		}
 	}
+	return nil
 }
 
 /*
 https://redis.io/docs/latest/commands/latency-histogram/
 */
-func (e *Exporter) extractLatencyHistogramMetrics(outChan chan<- prometheus.Metric, infoAll string, redisConn redis.Conn) {
+func (e *Exporter) extractLatencyHistogramMetrics(outChan chan<- prometheus.Metric, infoAll string, redisConn redis.Conn) error {
 	reply, err := redis.Values(doRedisCmd(redisConn, "LATENCY", "HISTOGRAM"))
 	if err != nil {
 		logHistogramErrOnce.Do(func() {
 			log.Errorf("WARNING, LOGGED ONCE ONLY: cmd LATENCY HISTOGRAM, err: %s", err)
 		})
 		log.Debugf("cmd LATENCY HISTOGRAM, err: %s", err)
-		return
+		return err
 	}
 
 	for i := 0; i < len(reply); i += 2 {
@@ -87,6 +91,7 @@ func (e *Exporter) extractLatencyHistogramMetrics(outChan chan<- prometheus.Metr
 		e.createMetricDescription("commands_latencies_usec", []string{"cmd"})
 		e.registerConstHistogram(outChan, "commands_latencies_usec", totalCalls, float64(totalUsecs), buckets, cmd)
 	}
+	return nil
 }
 
 func extractTotalUsecForCommand(infoAll string, cmd string) uint64 {
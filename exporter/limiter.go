@@ -0,0 +1,111 @@
+package exporter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// the reasons of target_scrape_requests_throttled_total
+const (
+	scrapeThrottledMaxConcurrent  = "max_concurrent"
+	scrapeThrottledTargetInFlight = "target_in_flight"
+	scrapeThrottledRateLimit      = "rate_limit"
+)
+
+var scrapeThrottledReasons = []string{scrapeThrottledMaxConcurrent, scrapeThrottledTargetInFlight, scrapeThrottledRateLimit}
+
+// scrapeLimiter limits the requests to the /scrape endpoint, requests over the limits are rejected instead of queued
+type scrapeLimiter struct {
+	maxConcurrent int  // 0 is unlimited
+	perTarget     bool // one scrape per target at a time
+	rate          float64
+	burst         float64
+
+	now func() time.Time // for the tests
+
+	mtx      sync.Mutex
+	inFlight int
+	targets  map[string]*targetScrapes
+	tokens   float64
+	last     time.Time
+}
+
+// targetScrapes are the requests in flight for a target, they share one scrape
+type targetScrapes struct {
+	key string
+	n   int
+}
+
+// newScrapeLimiter returns the limiter of the options, nil if there are no limits
+func newScrapeLimiter(opts Options) *scrapeLimiter {
+	if opts.ScrapeMaxConcurrent <= 0 && !opts.ScrapeOnePerTarget && opts.ScrapeRateLimit <= 0 {
+		return nil
+	}
+	l := &scrapeLimiter{
+		maxConcurrent: opts.ScrapeMaxConcurrent,
+		perTarget:     opts.ScrapeOnePerTarget,
+		rate:          opts.ScrapeRateLimit,
+		burst:         float64(opts.ScrapeRateBurst),
+		now:           time.Now,
+		targets:       map[string]*targetScrapes{},
+	}
+	if l.burst < 1 {
+		l.burst = math.Max(1, math.Ceil(l.rate))
+	}
+	l.tokens = l.burst
+	return l
+}
+
+// acquire reserves a scrape of target, key identifies the shared scrape of the request. Requests for a
+// target with the same key are allowed while it's in flight as they share one scrape, an empty key is a
+// request that isn't a scrape, e.g. a health check, and isn't limited per target. If the request is over
+// a limit it returns the reason and how long to wait before retrying, otherwise release must be called
+// when the scrape is done.
+func (l *scrapeLimiter) acquire(target, key string) (release func(), reason string, retryAfter time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.maxConcurrent > 0 && l.inFlight >= l.maxConcurrent {
+		return nil, scrapeThrottledMaxConcurrent, time.Second
+	}
+	ts := l.targets[target]
+	if l.perTarget && ts != nil && key != "" && ts.key != key {
+		return nil, scrapeThrottledTargetInFlight, time.Second
+	}
+	if l.rate > 0 {
+		now := l.now()
+		if !l.last.IsZero() {
+			l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		}
+		l.last = now
+		if l.tokens < 1 {
+			return nil, scrapeThrottledRateLimit, time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.tokens--
+	}
+
+	l.inFlight++
+	if key != "" {
+		if ts == nil {
+			ts = &targetScrapes{key: key}
+			l.targets[target] = ts
+		}
+		ts.n++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mtx.Lock()
+			defer l.mtx.Unlock()
+			l.inFlight--
+			if key == "" {
+				return
+			}
+			if ts.n--; ts.n == 0 {
+				delete(l.targets, target)
+			}
+		})
+	}, "", 0
+}
@@ -0,0 +1,214 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
+)
+
+func TestNewScrapeLimiter(t *testing.T) {
+	if l := newScrapeLimiter(Options{}); l != nil {
+		t.Errorf("expected no limiter without limits, got: %#v", l)
+	}
+	for _, tst := range []struct {
+		opts      Options
+		wantBurst float64
+	}{
+		{opts: Options{ScrapeMaxConcurrent: 2}, wantBurst: 1},
+		{opts: Options{ScrapeRateLimit: 5}, wantBurst: 5},
+		{opts: Options{ScrapeRateLimit: 0.5}, wantBurst: 1},
+		{opts: Options{ScrapeRateLimit: 2.5, ScrapeRateBurst: 10}, wantBurst: 10},
+	} {
+		l := newScrapeLimiter(tst.opts)
+		if l == nil || l.burst != tst.wantBurst || l.tokens != tst.wantBurst {
+			t.Errorf("%+v: got %#v, want burst %v", tst.opts, l, tst.wantBurst)
+		}
+	}
+}
+
+func TestScrapeLimiterAcquire(t *testing.T) {
+	l := newScrapeLimiter(Options{ScrapeMaxConcurrent: 3, ScrapeOnePerTarget: true})
+
+	releaseA, _, _ := l.acquire("a:6379", "default")
+	if releaseA == nil {
+		t.Fatal("expected the first scrape of a to be allowed")
+	}
+	if release, reason, _ := l.acquire("a:6379", "minimal"); release != nil || reason != scrapeThrottledTargetInFlight {
+		t.Errorf("expected a scrape of a with other parameters to be rejected, got reason %q", reason)
+	}
+	releaseShared, _, _ := l.acquire("a:6379", "default")
+	if releaseShared == nil {
+		t.Fatal("expected a scrape of a with the same parameters to be allowed")
+	}
+	releaseHealth, _, _ := l.acquire("a:6379", "")
+	if releaseHealth == nil {
+		t.Fatal("expected a health check of a to be allowed while a scrape is in flight")
+	}
+	releaseHealth()
+	releaseB, _, _ := l.acquire("b:6379", "default")
+	if releaseB == nil {
+		t.Fatal("expected the first scrape of b to be allowed")
+	}
+	if release, reason, _ := l.acquire("c:6379", "default"); release != nil || reason != scrapeThrottledMaxConcurrent {
+		t.Errorf("expected the scrape of c to be over the max, got reason %q", reason)
+	}
+
+	releaseA()
+	releaseA() // releasing twice doesn't free another slot
+	if release, reason, _ := l.acquire("a:6379", "minimal"); release != nil || reason != scrapeThrottledTargetInFlight {
+		t.Errorf("expected a to be in flight until the shared scrape is released, got reason %q", reason)
+	}
+	releaseShared()
+	release, reason, _ := l.acquire("a:6379", "minimal")
+	if release == nil {
+		t.Fatalf("expected a scrape of a with other parameters to be allowed, got reason %q", reason)
+	}
+	release()
+	releaseB()
+	if l.inFlight != 0 || len(l.targets) != 0 {
+		t.Errorf("expected no scrapes in flight, got %d and %v", l.inFlight, l.targets)
+	}
+}
+
+func TestScrapeLimiterRate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newScrapeLimiter(Options{ScrapeRateLimit: 2, ScrapeRateBurst: 3})
+	l.now = func() time.Time { return now }
+
+	acquire := func() (string, time.Duration) {
+		release, reason, retryAfter := l.acquire("a:6379", "")
+		if release != nil {
+			release()
+		}
+		return reason, retryAfter
+	}
+
+	for i := 0; i < 3; i++ {
+		if reason, _ := acquire(); reason != "" {
+			t.Fatalf("request %d: expected the burst to be allowed, got reason %q", i, reason)
+		}
+	}
+	if reason, retryAfter := acquire(); reason != scrapeThrottledRateLimit || retryAfter != 500*time.Millisecond {
+		t.Errorf("expected the rate limit with retry after 500ms, got reason %q, retry after %s", reason, retryAfter)
+	}
+
+	now = now.Add(250 * time.Millisecond)
+	if reason, retryAfter := acquire(); reason != scrapeThrottledRateLimit || retryAfter != 250*time.Millisecond {
+		t.Errorf("expected the rate limit with retry after 250ms, got reason %q, retry after %s", reason, retryAfter)
+	}
+	now = now.Add(250 * time.Millisecond)
+	if reason, _ := acquire(); reason != "" {
+		t.Errorf("expected a token after 500ms, got reason %q", reason)
+	}
+
+	// the bucket doesn't fill up over the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if reason, _ := acquire(); reason != "" {
+			t.Fatalf("request %d: expected the burst to be allowed, got reason %q", i, reason)
+		}
+	}
+	if reason, _ := acquire(); reason != scrapeThrottledRateLimit {
+		t.Errorf("expected the rate limit after the burst, got reason %q", reason)
+	}
+}
+
+func TestScrapeHandlerLimits(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+
+	// INFO blocks until unblock is closed so the scrapes stay in flight
+	started := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	s.Handle("INFO", func([]string) interface{} {
+		started <- struct{}{}
+		<-unblock
+		return "# Server\r\nredis_version:7.2.4\r\n"
+	})
+
+	e, _ := NewRedisExporter("", Options{
+		Namespace:           "test",
+		Registry:            prometheus.NewRegistry(),
+		Modules:             map[string]ModuleConfig{"minimal": {}},
+		ScrapeMaxConcurrent: 2,
+		ScrapeOnePerTarget:  true,
+	})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	scrapeURL := ts.URL + "/scrape?target=" + url.QueryEscape(s.URI())
+	var wg sync.WaitGroup
+	scrape := func(u string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code, body := downloadURLWithStatusCode(t, u); code != http.StatusOK {
+				t.Errorf("%s: got %d, want 200:\n%s", u, code, body)
+			}
+		}()
+	}
+	scrape(scrapeURL)
+	<-started
+
+	resp, err := http.Get(scrapeURL + "&module=minimal")
+	if err != nil {
+		t.Fatalf("http.Get() err: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("expected a 429 with Retry-After for a scrape of the target with another module, got %d, Retry-After: %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if code, _ := downloadURLWithStatusCode(t, ts.URL+"/health?target="+url.QueryEscape(s.URI())); code != http.StatusOK {
+		t.Errorf("expected the health check of the target to be allowed, got %d", code)
+	}
+
+	// requests that differ only in check-keys or collect[] would run another scrape of the target
+	for _, u := range []string{scrapeURL + "&check-keys=db0=key", scrapeURL + "&collect[]=info"} {
+		if code, _ := downloadURLWithStatusCode(t, u); code != http.StatusTooManyRequests {
+			t.Errorf("%s: expected a 429 for another scrape of the target, got %d", u, code)
+		}
+	}
+
+	// a request with the same parameters shares the scrape
+	scrape(scrapeURL)
+	for i := 0; ; i++ {
+		e.scrapeLimiter.mtx.Lock()
+		inFlight := e.scrapeLimiter.inFlight
+		e.scrapeLimiter.mtx.Unlock()
+		if inFlight == 2 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("expected 2 scrapes in flight, got %d", inFlight)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if code, _ := downloadURLWithStatusCode(t, ts.URL+"/scrape?target=localhost:1"); code != http.StatusTooManyRequests {
+		t.Errorf("expected a 429 over the max concurrent scrapes, got %d", code)
+	}
+	if code, _ := downloadURLWithStatusCode(t, ts.URL+"/health?target=localhost:1"); code != http.StatusTooManyRequests {
+		t.Errorf("expected a 429 for the check of a target over the max concurrent scrapes, got %d", code)
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	if code, body := downloadURLWithStatusCode(t, scrapeURL+"&module=minimal"); code != http.StatusOK {
+		t.Errorf("expected the scrape to be allowed once the others are done, got %d:\n%s", code, body)
+	}
+
+	for reason, want := range map[string]float64{scrapeThrottledTargetInFlight: 3, scrapeThrottledMaxConcurrent: 2, scrapeThrottledRateLimit: 0} {
+		m := &dto.Metric{}
+		if err := e.targetScrapeThrottled.WithLabelValues(reason).Write(m); err != nil || m.GetCounter().GetValue() != want {
+			t.Errorf("%s: got %v, err: %v, want %v", reason, m.GetCounter().GetValue(), err, want)
+		}
+	}
+}
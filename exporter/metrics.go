@@ -89,6 +89,10 @@ func (e *Exporter) registerConstMetric(ch chan<- prometheus.Metric, metric strin
 		desc = e.mustFindMetricDescription(metric)
 	}
 
+	if e.filtered(metric, labelValues) {
+		return
+	}
+
 	m, err := prometheus.NewConstMetric(desc, valType, val, labelValues...)
 	if err != nil {
 		log.Debugf("registerConstMetric( %s , %.2f) err: %s", metric, val, err)
@@ -99,6 +103,10 @@ func (e *Exporter) registerConstMetric(ch chan<- prometheus.Metric, metric strin
 }
 
 func (e *Exporter) registerConstSummary(ch chan<- prometheus.Metric, metric string, count uint64, sum float64, latencyMap map[float64]float64, labelValues ...string) {
+	if e.filtered(metric, labelValues) {
+		return
+	}
+
 	// Create a constant summary from values we got from a 3rd party telemetry system.
 	summary := prometheus.MustNewConstSummary(
 		e.mustFindMetricDescription(metric),
@@ -110,6 +118,10 @@ func (e *Exporter) registerConstSummary(ch chan<- prometheus.Metric, metric stri
 }
 
 func (e *Exporter) registerConstHistogram(ch chan<- prometheus.Metric, metric string, count uint64, sum float64, buckets map[float64]uint64, labelValues ...string) {
+	if e.filtered(metric, labelValues) {
+		return
+	}
+
 	histogram := prometheus.MustNewConstHistogram(
 		e.mustFindMetricDescription(metric),
 		count, sum,
@@ -120,7 +132,9 @@ func (e *Exporter) registerConstHistogram(ch chan<- prometheus.Metric, metric st
 }
 
 func (e *Exporter) mustFindMetricDescription(metricName string) *prometheus.Desc {
+	e.metricDescriptionsMtx.RLock()
 	description, found := e.metricDescriptions[metricName]
+	e.metricDescriptionsMtx.RUnlock()
 	if !found {
 		panic(fmt.Sprintf("couldn't find metric description for %s", metricName))
 	}
@@ -128,10 +142,25 @@ func (e *Exporter) mustFindMetricDescription(metricName string) *prometheus.Desc
 }
 
 func (e *Exporter) createMetricDescription(metricName string, labels []string) *prometheus.Desc {
+	e.metricDescriptionsMtx.Lock()
+	defer e.metricDescriptionsMtx.Unlock()
+
 	if desc, found := e.metricDescriptions[metricName]; found {
 		return desc
 	}
 	d := newMetricDescr(e.options.Namespace, metricName, metricName+" metric", labels)
 	e.metricDescriptions[metricName] = d
+	e.metricLabelNames[metricName] = labels
 	return d
 }
+
+// filtered reports whether the series is dropped by the MetricFilter
+func (e *Exporter) filtered(metric string, labelValues []string) bool {
+	if e.options.MetricFilter == nil {
+		return false
+	}
+	e.metricDescriptionsMtx.RLock()
+	labelNames := e.metricLabelNames[metric]
+	e.metricDescriptionsMtx.RUnlock()
+	return !e.options.MetricFilter.keep(prometheus.BuildFQName(e.options.Namespace, "", metric), labelNames, labelValues)
+}
@@ -8,11 +8,11 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func (e *Exporter) extractModulesMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+func (e *Exporter) extractModulesMetrics(ch chan<- prometheus.Metric, c redis.Conn) error {
 	info, err := redis.String(doRedisCmd(c, "INFO", "MODULES"))
 	if err != nil {
 		log.Errorf("extractSearchMetrics() err: %s", err)
-		return
+		return err
 	}
 
 	lines := strings.Split(info, "\r\n")
@@ -49,4 +49,5 @@ func (e *Exporter) extractModulesMetrics(ch chan<- prometheus.Metric, c redis.Co
 		}
 		e.parseAndRegisterConstMetric(ch, fieldKey, fieldValue)
 	}
+	return nil
 }
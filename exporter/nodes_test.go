@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"os"
 	"slices"
 	"testing"
@@ -13,7 +14,7 @@ func TestNodesGetClusterNodes(t *testing.T) {
 	}
 
 	e, _ := NewRedisExporter(host, Options{})
-	c, err := e.connectToRedisCluster()
+	c, err := e.connectToRedisCluster(context.Background())
 	if err != nil {
 		t.Fatalf("connectToRedisCluster() err: %s", err)
 	}
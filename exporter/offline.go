@@ -0,0 +1,156 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Dump holds the output of commands that were captured with redis-cli on an instance
+// the exporter can't reach, the exporter exports the same metrics from it as from
+// the live instance for the collectors that only depend on these commands
+type Dump struct {
+	Info        string // INFO ALL
+	Config      string // CONFIG GET *, empty if it wasn't captured
+	ClientList  string // CLIENT LIST, empty if it wasn't captured
+	Slowlog     string // SLOWLOG GET, empty if it wasn't captured
+	ClusterInfo string // CLUSTER INFO, empty if it wasn't captured
+}
+
+// dumpFiles are the files LoadDump reads, only info.txt is required
+var dumpFiles = map[string]func(d *Dump) *string{
+	"info.txt":         func(d *Dump) *string { return &d.Info },
+	"config.txt":       func(d *Dump) *string { return &d.Config },
+	"client_list.txt":  func(d *Dump) *string { return &d.ClientList },
+	"slowlog.txt":      func(d *Dump) *string { return &d.Slowlog },
+	"cluster_info.txt": func(d *Dump) *string { return &d.ClusterInfo },
+}
+
+// LoadDump reads the output of INFO ALL, CONFIG GET *, CLIENT LIST, SLOWLOG GET and
+// CLUSTER INFO from info.txt, config.txt, client_list.txt, slowlog.txt and
+// cluster_info.txt in dir, all files but info.txt are optional
+func LoadDump(dir string) (*Dump, error) {
+	d := &Dump{}
+	for name, field := range dumpFiles {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) && name != "info.txt" {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		*field(d) = strings.ReplaceAll(string(content), "\r\n", "\n")
+	}
+	if !strings.Contains(d.Info, ":") {
+		return nil, fmt.Errorf("%s doesn't contain the output of INFO", filepath.Join(dir, "info.txt"))
+	}
+	return d, nil
+}
+
+// redisCLIPrefixRE matches the array indices and type hints that redis-cli adds
+// when it prints to a terminal, e.g. `1) 2) (integer) `
+var redisCLIPrefixRE = regexp.MustCompile(`^\s*(?:\d+\)\s+)*(?:\(integer\)\s+)?`)
+
+// parseRedisCLIValues returns the elements of an array reply printed by redis-cli, nested
+// arrays are flattened. It understands the raw format (one element per line, used when the
+// output isn't a terminal) as well as the quoted format with indices of the interactive mode.
+func parseRedisCLIValues(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" || strings.HasPrefix(strings.TrimSpace(s), "(empty") {
+		return nil
+	}
+
+	var values []string
+	for _, line := range strings.Split(s, "\n") {
+		val := redisCLIPrefixRE.ReplaceAllString(line, "")
+		if len(val) >= 2 && strings.HasPrefix(val, `"`) && strings.HasSuffix(val, `"`) {
+			if unquoted, err := strconv.Unquote(val); err == nil {
+				val = unquoted
+			} else {
+				val = val[1 : len(val)-1]
+			}
+		}
+		values = append(values, val)
+	}
+	return values
+}
+
+// slowlogFromDump returns the latest entry of the output of SLOWLOG GET like it's returned by redigo
+func slowlogFromDump(s string) ([]interface{}, error) {
+	values := parseRedisCLIValues(s)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if len(values) < 3 {
+		return nil, fmt.Errorf("invalid SLOWLOG GET output: %q", s)
+	}
+
+	entry := make([]interface{}, 3)
+	for i := range entry {
+		n, err := strconv.ParseInt(strings.TrimSpace(values[i]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SLOWLOG GET output, err: %w", err)
+		}
+		entry[i] = n
+	}
+	return []interface{}{entry}, nil
+}
+
+// scrapeDump exports the metrics of Options.Dump through the same parsers that are used for
+// the replies of a live instance, collectors limits the run like for scrapeRedisHost
+func (e *Exporter) scrapeDump(ctx context.Context, ch chan<- prometheus.Metric, collectors map[string]bool) error {
+	d := e.options.Dump
+	run := func(name string, enabled bool, collect func() error) error {
+		if !enabled || (collectors != nil && !collectors[name]) {
+			return nil
+		}
+		return e.runCollector(ctx, ch, name, func(ctx context.Context) error { return collect() })
+	}
+
+	dbCount := 0
+	if err := run("config", d.Config != "" && e.options.ConfigCommandName != "-", func() error {
+		values := parseRedisCLIValues(d.Config)
+		config := make([]interface{}, len(values))
+		for i, v := range values {
+			config[i] = v
+		}
+		var err error
+		dbCount, err = e.extractConfigMetrics(ch, config)
+		return err
+	}); err != nil {
+		log.Errorf("extractConfigMetrics() err: %s", err)
+		return err
+	}
+
+	dbCount = instanceDBCount(d.Info, dbCount)
+	_ = run("cluster_info", d.ClusterInfo != "" && strings.Contains(d.Info, "cluster_enabled:1"), func() error {
+		e.extractClusterInfoMetrics(ch, d.ClusterInfo)
+		return nil
+	})
+	_ = run("info", true, func() error {
+		e.extractInfoMetrics(ch, d.Info, dbCount)
+		return nil
+	})
+	_ = run("slowlog", d.Slowlog != "", func() error {
+		values, err := slowlogFromDump(d.Slowlog)
+		if err != nil {
+			log.Errorf("slowlog dump err: %s", err)
+			return err
+		}
+		e.extractSlowLogGetMetrics(ch, values)
+		return nil
+	})
+	_ = run("client_list", d.ClientList != "" && e.options.ExportClientList, func() error {
+		e.parseConnectedClientMetrics(d.ClientList, ch)
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,89 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRedisCLIValues(t *testing.T) {
+	for _, tst := range []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "raw", input: "maxmemory\n0\nappendfilename\n\nsave\n3600 1 300 100\n", want: []string{"maxmemory", "0", "appendfilename", "", "save", "3600 1 300 100"}},
+		{name: "interactive", input: "1) \"maxmemory\"\n2) \"0\"\n3) \"save\"\n4) \"3600 1 300 100\"\n", want: []string{"maxmemory", "0", "save", "3600 1 300 100"}},
+		{name: "nested", input: "1) 1) (integer) 14\n   2) (integer) 1309448221\n   3) (integer) 15\n   4) 1) \"ping\"\n", want: []string{"14", "1309448221", "15", "ping"}},
+		{name: "empty", input: "(empty array)\n", want: nil},
+	} {
+		t.Run(tst.name, func(t *testing.T) {
+			if got := parseRedisCLIValues(tst.input); !reflect.DeepEqual(got, tst.want) {
+				t.Errorf("got: %#v, want: %#v", got, tst.want)
+			}
+		})
+	}
+}
+
+func TestScrapeDump(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"info.txt":        "# Server\r\nredis_version:7.2.4\r\nuptime_in_seconds:100\r\n\r\n# Clients\r\nconnected_clients:2\r\n\r\n# Keyspace\r\ndb0:keys=10,expires=1,avg_ttl=0\r\n",
+		"config.txt":      "1) \"maxmemory\"\n2) \"1024\"\n3) \"databases\"\n4) \"4\"\n",
+		"client_list.txt": "id=3 addr=127.0.0.1:51234 laddr=127.0.0.1:6379 fd=8 name=app age=10 idle=0 flags=N db=0 sub=0 psub=0 ssub=0 multi=-1 qbuf=26 qbuf-free=20448 argv-mem=10 multi-mem=0 rbs=1024 rbp=0 obl=0 oll=0 omem=0 tot-mem=22298 events=r cmd=client|list user=default redir=-1 resp=2\n",
+		"slowlog.txt":     "7\n1700000000\n25000\nKEYS\n*\n127.0.0.1:51234\n\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := LoadDump(dir)
+	if err != nil {
+		t.Fatalf("LoadDump() err: %s", err)
+	}
+	e, _ := NewRedisExporter("", Options{Namespace: "test", Dump: d, InclMetricsForEmptyDatabases: true, ExportClientList: true})
+	var buf bytes.Buffer
+	up, err := e.WriteMetrics(context.Background(), &buf)
+	if err != nil || !up {
+		t.Fatalf("WriteMetrics() up: %t, err: %v", up, err)
+	}
+
+	for _, want := range []string{
+		"\ntest_up 1\n",
+		"\ntest_uptime_in_seconds 100\n",
+		"\ntest_connected_clients 2\n",
+		`test_db_keys{db="db0"} 10`,
+		`test_db_keys{db="db3"} 0`,
+		"\ntest_config_maxmemory 1024\n",
+		`test_connected_client_info{db="0",flags="N",host="127.0.0.1",id="3",name="app",resp="2",user="default"} 1`,
+		"\ntest_slowlog_last_id 7\n",
+		"\ntest_last_slow_execution_duration_seconds 0.025\n",
+		`test_exporter_collector_success{collector="client_list"} 1`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("didn't find %q in:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `collector="latency"`) {
+		t.Errorf("didn't expect the latency collector to run")
+	}
+
+	// like for a live instance the client list is only exported with ExportClientList
+	e, _ = NewRedisExporter("", Options{Namespace: "test", Dump: d})
+	buf.Reset()
+	if _, err := e.WriteMetrics(context.Background(), &buf); err != nil {
+		t.Fatalf("WriteMetrics() err: %s", err)
+	}
+	if strings.Contains(buf.String(), "test_connected_client_info") || strings.Contains(buf.String(), `collector="client_list"`) {
+		t.Errorf("didn't expect the client list without ExportClientList in:\n%s", buf.String())
+	}
+
+	if _, err := LoadDump(t.TempDir()); err == nil {
+		t.Errorf("expected an error for a dir without info.txt")
+	}
+}
@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+// WriteMetrics scrapes the exporter (or all its targets) once and writes the metrics in
+// the text exposition format, it returns false if the up metric of any instance is 0 or missing
+func (e *Exporter) WriteMetrics(ctx context.Context, w io.Writer) (bool, error) {
+	gatherer, done, err := e.gatherer(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	mfs, err := gatherer.Gather()
+	done()
+	if err != nil {
+		return false, err
+	}
+
+	up := false
+	for _, mf := range mfs {
+		if mf.GetName() == e.options.Namespace+"_up" {
+			up = len(mf.GetMetric()) > 0
+			for _, m := range mf.GetMetric() {
+				up = up && m.GetGauge().GetValue() == 1
+			}
+		}
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return false, err
+		}
+	}
+	return up, nil
+}
+
+// WriteMetricsFile is like WriteMetrics but writes the metrics to a file, e.g. for the textfile
+// collector of the node_exporter. The file is replaced atomically so it's never read half written.
+func (e *Exporter) WriteMetricsFile(ctx context.Context, path string) (bool, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(f.Name())
+
+	up, err := e.WriteMetrics(ctx, f)
+	if err != nil {
+		f.Close()
+		return false, err
+	}
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+	// CreateTemp creates the file with mode 0600, the textfile collector may run as another user
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return false, fmt.Errorf("couldn't replace %s: %w", path, err)
+	}
+	return up, nil
+}
@@ -0,0 +1,62 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+	e, _ := NewRedisExporter(s.URI(), Options{Namespace: "test"})
+	var buf bytes.Buffer
+	up, err := e.WriteMetrics(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("WriteMetrics() err: %s", err)
+	}
+	if !up || !strings.Contains(buf.String(), "\ntest_up 1\n") {
+		t.Errorf("expected the instance to be up, got: %t\n%s", up, buf.String())
+	}
+}
+
+func TestWriteMetricsWithoutUp(t *testing.T) {
+	// an exporter without an address doesn't scrape and has no up metric
+	e, _ := NewRedisExporter("", Options{Namespace: "test"})
+	var buf bytes.Buffer
+	if up, err := e.WriteMetrics(context.Background(), &buf); err != nil || up {
+		t.Errorf("expected the instance not to be up without an up metric, got: %t, err: %v\n%s", up, err, buf.String())
+	}
+}
+
+func TestWriteMetricsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.prom")
+	if err := os.WriteFile(path, []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, _ := NewRedisExporter("redis://localhost:1", Options{Namespace: "test", ConnectionTimeouts: 100 * time.Millisecond})
+	up, err := e.WriteMetricsFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("WriteMetricsFile() err: %s", err)
+	}
+	if up {
+		t.Errorf("expected the instance to be down")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "\ntest_up 0\n") {
+		t.Errorf("unexpected file content:\n%s", content)
+	}
+	if files, _ := os.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("expected the temporary file to be removed, got %d files", len(files))
+	}
+}
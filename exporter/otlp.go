@@ -0,0 +1,378 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	OTLPProtocolHTTP = "http/protobuf"
+	OTLPProtocolGRPC = "grpc"
+)
+
+// OTLPConfig configures the push of the metrics to an OpenTelemetry collector
+type OTLPConfig struct {
+	// Endpoint is the URL of the receiver, e.g. http://collector:4318 or https://collector:4317 for gRPC.
+	// For OTLP/HTTP the path defaults to /v1/metrics, for gRPC the http scheme means no TLS.
+	Endpoint string
+	Protocol string
+	Interval time.Duration
+	Timeout  time.Duration
+	Headers  map[string]string
+}
+
+// OTLPPusher runs the scrapes of an exporter on an interval and pushes the metrics via OTLP
+type OTLPPusher struct {
+	cfg       OTLPConfig
+	startTime time.Time
+	buildInfo BuildInfo
+
+	httpURL    string
+	httpClient *http.Client
+	grpcConn   *grpc.ClientConn
+	grpcClient colmetricspb.MetricsServiceClient
+}
+
+// NewOTLPPusher returns a pusher for the given config, Close releases its connection
+func NewOTLPPusher(cfg OTLPConfig, buildInfo BuildInfo) (*OTLPPusher, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q, expected http(s)://<host>:<port>", cfg.Endpoint)
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("OTLP push interval must be positive")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = cfg.Interval
+	}
+
+	p := &OTLPPusher{cfg: cfg, startTime: time.Now(), buildInfo: buildInfo}
+	switch cfg.Protocol {
+	case OTLPProtocolHTTP, "":
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/v1/metrics"
+		}
+		p.httpURL = u.String()
+		p.httpClient = &http.Client{Timeout: cfg.Timeout}
+	case OTLPProtocolGRPC:
+		creds := insecure.NewCredentials()
+		if u.Scheme == "https" {
+			creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		}
+		p.grpcConn, err = grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create OTLP gRPC client: %w", err)
+		}
+		p.grpcClient = colmetricspb.NewMetricsServiceClient(p.grpcConn)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q, expected %s or %s", cfg.Protocol, OTLPProtocolHTTP, OTLPProtocolGRPC)
+	}
+	return p, nil
+}
+
+// Close releases the connection of the pusher
+func (p *OTLPPusher) Close() error {
+	if p.grpcConn != nil {
+		return p.grpcConn.Close()
+	}
+	return nil
+}
+
+// Run pushes the metrics of the exporter returned by exporter every interval until ctx is done
+func (p *OTLPPusher) Run(ctx context.Context, exporter func() *Exporter) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Push(ctx, exporter()); err != nil {
+			log.Errorf("Error pushing metrics via OTLP to %s, err: %s", p.cfg.Endpoint, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push scrapes the exporter (or all its targets) once and sends the metrics,
+// every target is sent as a resource with its labels as attributes
+func (p *OTLPPusher) Push(ctx context.Context, e *Exporter) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+
+	targets := e.targets
+	if len(targets) == 0 {
+		targets = []target{{exporter: e, labels: prometheus.Labels{instanceLabel: targetInstance(e.redisAddr)}}}
+	}
+
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+	if e.options.Registry != nil {
+		// the exporter (or its targets) is registered with the registry, its metrics are split by target
+		gatherer, done, err := e.gatherer(ctx, nil)
+		if err != nil {
+			return err
+		}
+		mfs, err := gatherer.Gather()
+		done()
+		if err != nil {
+			log.Errorf("Error gathering metrics, err: %s", err)
+		}
+		byTarget, others := splitTargetMetrics(e.options.Namespace, targets, mfs)
+		for i, t := range targets {
+			req.ResourceMetrics = append(req.ResourceMetrics, p.resourceMetrics(t.labels, byTarget[i]))
+		}
+		req.ResourceMetrics = append(req.ResourceMetrics, p.resourceMetrics(nil, others))
+	} else {
+		for _, t := range targets {
+			registry := prometheus.NewRegistry()
+			if err := registry.Register(&scrape{ctx: ctx, e: t.exporter}); err != nil {
+				return err
+			}
+			mfs, err := registry.Gather()
+			if err != nil {
+				log.Errorf("Error gathering metrics of %s, err: %s", t.labels[instanceLabel], err)
+			}
+			req.ResourceMetrics = append(req.ResourceMetrics, p.resourceMetrics(t.labels, mfs))
+		}
+	}
+
+	if p.grpcClient != nil {
+		return p.pushGRPC(ctx, req)
+	}
+	return p.pushHTTP(ctx, req)
+}
+
+func (p *OTLPPusher) pushHTTP(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.httpURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range p.cfg.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("OTLP receiver returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (p *OTLPPusher) pushGRPC(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	if len(p.cfg.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(p.cfg.Headers))
+	}
+	resp, err := p.grpcClient.Export(ctx, req)
+	if err != nil {
+		return err
+	}
+	if ps := resp.GetPartialSuccess(); ps != nil && ps.GetRejectedDataPoints() > 0 {
+		return fmt.Errorf("OTLP receiver rejected %d data points: %s", ps.GetRejectedDataPoints(), ps.GetErrorMessage())
+	}
+	return nil
+}
+
+// splitTargetMetrics splits the metrics gathered from Options.Registry into the ones of each target,
+// by the instance label if there are several, and the others like the build info and the process metrics
+func splitTargetMetrics(namespace string, targets []target, mfs []*dto.MetricFamily) (byTarget [][]*dto.MetricFamily, others []*dto.MetricFamily) {
+	byTarget = make([][]*dto.MetricFamily, len(targets))
+	index := map[string]int{}
+	for i, t := range targets {
+		index[t.labels[instanceLabel]] = i
+	}
+
+	for _, mf := range mfs {
+		if !strings.HasPrefix(mf.GetName(), namespace+"_") || mf.GetName() == namespace+"_exporter_build_info" {
+			others = append(others, mf)
+			continue
+		}
+		if len(targets) == 1 {
+			byTarget[0] = append(byTarget[0], mf)
+			continue
+		}
+
+		split := make([]*dto.MetricFamily, len(targets))
+		for _, m := range mf.GetMetric() {
+			i, ok := -1, false
+			for _, l := range m.GetLabel() {
+				if l.GetName() == instanceLabel {
+					i, ok = index[l.GetValue()]
+				}
+			}
+			if !ok {
+				continue
+			}
+			if split[i] == nil {
+				split[i] = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Unit: mf.Unit}
+				byTarget[i] = append(byTarget[i], split[i])
+			}
+			split[i].Metric = append(split[i].Metric, m)
+		}
+	}
+	return byTarget, others
+}
+
+func (p *OTLPPusher) resourceMetrics(labels prometheus.Labels, mfs []*dto.MetricFamily) *metricspb.ResourceMetrics {
+	attrs := []*commonpb.KeyValue{stringAttr("service.name", "redis_exporter")}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		attrs = append(attrs, stringAttr(name, labels[name]))
+	}
+
+	return &metricspb.ResourceMetrics{
+		Resource: &resourcepb.Resource{Attributes: attrs},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{
+			Scope: &commonpb.InstrumentationScope{
+				Name:    "github.com/oliver006/redis_exporter",
+				Version: p.buildInfo.Version,
+			},
+			Metrics: metricFamiliesToOTLP(mfs, labels, p.startTime, time.Now()),
+		}},
+	}
+}
+
+func stringAttr(key, val string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: val}}}
+}
+
+// metricFamiliesToOTLP converts gathered metrics into OTLP metrics, labels that
+// are resource attributes are left out of the data point attributes
+func metricFamiliesToOTLP(mfs []*dto.MetricFamily, resourceLabels prometheus.Labels, start, now time.Time) []*metricspb.Metric {
+	startNano, nowNano := uint64(start.UnixNano()), uint64(now.UnixNano())
+
+	metrics := make([]*metricspb.Metric, 0, len(mfs))
+	for _, mf := range mfs {
+		m := &metricspb.Metric{Name: mf.GetName(), Description: mf.GetHelp()}
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}
+			for _, pm := range mf.GetMetric() {
+				sum.DataPoints = append(sum.DataPoints, &metricspb.NumberDataPoint{
+					Attributes:        pointAttrs(pm, resourceLabels),
+					StartTimeUnixNano: startNano,
+					TimeUnixNano:      nowNano,
+					Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: pm.GetCounter().GetValue()},
+				})
+			}
+			m.Data = &metricspb.Metric_Sum{Sum: sum}
+
+		case dto.MetricType_SUMMARY:
+			summary := &metricspb.Summary{}
+			for _, pm := range mf.GetMetric() {
+				dp := &metricspb.SummaryDataPoint{
+					Attributes:        pointAttrs(pm, resourceLabels),
+					StartTimeUnixNano: startNano,
+					TimeUnixNano:      nowNano,
+					Count:             pm.GetSummary().GetSampleCount(),
+					Sum:               pm.GetSummary().GetSampleSum(),
+				}
+				for _, q := range pm.GetSummary().GetQuantile() {
+					dp.QuantileValues = append(dp.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+						Quantile: q.GetQuantile(),
+						Value:    q.GetValue(),
+					})
+				}
+				summary.DataPoints = append(summary.DataPoints, dp)
+			}
+			m.Data = &metricspb.Metric_Summary{Summary: summary}
+
+		case dto.MetricType_HISTOGRAM:
+			histogram := &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			}
+			for _, pm := range mf.GetMetric() {
+				h := pm.GetHistogram()
+				sum := h.GetSampleSum()
+				dp := &metricspb.HistogramDataPoint{
+					Attributes:        pointAttrs(pm, resourceLabels),
+					StartTimeUnixNano: startNano,
+					TimeUnixNano:      nowNano,
+					Count:             h.GetSampleCount(),
+					Sum:               &sum,
+				}
+				// Prometheus buckets are cumulative and the +Inf bucket is implicit,
+				// OTLP wants the count per bucket with one more count than bounds
+				var prev uint64
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						continue
+					}
+					dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+					dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-prev)
+					prev = b.GetCumulativeCount()
+				}
+				dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-prev)
+				histogram.DataPoints = append(histogram.DataPoints, dp)
+			}
+			m.Data = &metricspb.Metric_Histogram{Histogram: histogram}
+
+		default:
+			gauge := &metricspb.Gauge{}
+			for _, pm := range mf.GetMetric() {
+				val := pm.GetGauge().GetValue()
+				if pm.GetUntyped() != nil {
+					val = pm.GetUntyped().GetValue()
+				}
+				gauge.DataPoints = append(gauge.DataPoints, &metricspb.NumberDataPoint{
+					Attributes:   pointAttrs(pm, resourceLabels),
+					TimeUnixNano: nowNano,
+					Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: val},
+				})
+			}
+			m.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func pointAttrs(pm *dto.Metric, resourceLabels prometheus.Labels) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(pm.GetLabel()))
+	for _, l := range pm.GetLabel() {
+		if _, ok := resourceLabels[l.GetName()]; ok {
+			continue
+		}
+		attrs = append(attrs, stringAttr(l.GetName(), l.GetValue()))
+	}
+	return attrs
+}
@@ -0,0 +1,190 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMetricFamiliesToOTLP(t *testing.T) {
+	r := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_up"}, []string{"instance"})
+	gauge.WithLabelValues("localhost:6379").Set(1)
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_commands_total"}, []string{"instance", "cmd"})
+	counter.WithLabelValues("localhost:6379", "get").Add(42)
+	r.MustRegister(gauge, counter, &constCollector{
+		prometheus.MustNewConstSummary(prometheus.NewDesc("test_latency_percentiles_usec", "", []string{"cmd"}, nil),
+			10, 100, map[float64]float64{0.5: 5, 0.99: 20}, "get"),
+		prometheus.MustNewConstHistogram(prometheus.NewDesc("test_commands_latencies_usec", "", []string{"cmd"}, nil),
+			10, 100, map[float64]uint64{1: 2, 10: 7, 100: 9}, "get"),
+	})
+	mfs, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := map[string]*metricspb.Metric{}
+	for _, m := range metricFamiliesToOTLP(mfs, prometheus.Labels{"instance": "localhost:6379"}, time.Now().Add(-time.Minute), time.Now()) {
+		metrics[m.GetName()] = m
+	}
+
+	if dp := metrics["test_up"].GetGauge().GetDataPoints(); len(dp) != 1 || dp[0].GetAsDouble() != 1 || len(dp[0].GetAttributes()) != 0 {
+		t.Errorf("unexpected gauge: %v", metrics["test_up"])
+	}
+
+	sum := metrics["test_commands_total"].GetSum()
+	if !sum.GetIsMonotonic() || sum.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE ||
+		sum.GetDataPoints()[0].GetAsDouble() != 42 || sum.GetDataPoints()[0].GetAttributes()[0].GetKey() != "cmd" {
+		t.Errorf("unexpected sum: %v", sum)
+	}
+
+	summary := metrics["test_latency_percentiles_usec"].GetSummary().GetDataPoints()[0]
+	if summary.GetCount() != 10 || summary.GetSum() != 100 || len(summary.GetQuantileValues()) != 2 {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+
+	hist := metrics["test_commands_latencies_usec"].GetHistogram().GetDataPoints()[0]
+	wantCounts := []uint64{2, 5, 2, 1}
+	if hist.GetCount() != 10 || hist.GetSum() != 100 || len(hist.GetExplicitBounds()) != 3 || len(hist.GetBucketCounts()) != len(wantCounts) {
+		t.Fatalf("unexpected histogram: %v", hist)
+	}
+	for i, c := range wantCounts {
+		if hist.GetBucketCounts()[i] != c {
+			t.Errorf("bucket %d: got %d, want %d", i, hist.GetBucketCounts()[i], c)
+		}
+	}
+}
+
+type constCollector []prometheus.Metric
+
+func (c *constCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range *c {
+		ch <- m.Desc()
+	}
+}
+
+func (c *constCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range *c {
+		ch <- m
+	}
+}
+
+func checkOTLPRequest(t *testing.T, req *colmetricspb.ExportMetricsServiceRequest) {
+	t.Helper()
+	if len(req.GetResourceMetrics()) != 2 {
+		t.Fatalf("expected a resource for the target and one for the exporter, got: %d", len(req.GetResourceMetrics()))
+	}
+	attrs := map[string]string{}
+	for _, a := range req.GetResourceMetrics()[0].GetResource().GetAttributes() {
+		attrs[a.GetKey()] = a.GetValue().GetStringValue()
+	}
+	if attrs["service.name"] != "redis_exporter" || attrs["instance"] != "redis://localhost:6379" {
+		t.Errorf("unexpected resource attributes: %v", attrs)
+	}
+	found := false
+	for _, m := range req.GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics() {
+		if m.GetName() == "test_exporter_scrapes_total" && m.GetSum() != nil {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("didn't find test_exporter_scrapes_total")
+	}
+}
+
+func TestOTLPPushHTTP(t *testing.T) {
+	reqs := make(chan *colmetricspb.ExportMetricsServiceRequest, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("Content-Type") != "application/x-protobuf" || r.Header.Get("Authorization") != "Bearer xyz" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		req := &colmetricspb.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reqs <- req
+	}))
+	defer ts.Close()
+
+	e, _ := NewRedisExporter("redis://localhost:6379", Options{Namespace: "test", Registry: prometheus.NewRegistry(), ConnectionTimeouts: 100 * time.Millisecond})
+	p, err := NewOTLPPusher(OTLPConfig{Endpoint: ts.URL, Interval: time.Minute, Headers: map[string]string{"Authorization": "Bearer xyz"}}, BuildInfo{})
+	if err != nil {
+		t.Fatalf("NewOTLPPusher() err: %s", err)
+	}
+	if err := p.Push(context.Background(), e); err != nil {
+		t.Fatalf("Push() err: %s", err)
+	}
+	checkOTLPRequest(t, <-reqs)
+
+	p.httpURL = ts.URL + "/wrong"
+	if err := p.Push(context.Background(), e); err == nil {
+		t.Errorf("expected an error when the receiver rejects the request")
+	}
+}
+
+type testMetricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	reqs chan *colmetricspb.ExportMetricsServiceRequest
+}
+
+func (s *testMetricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("authorization")) == 0 {
+		return &colmetricspb.ExportMetricsServiceResponse{PartialSuccess: &colmetricspb.ExportMetricsPartialSuccess{RejectedDataPoints: 1, ErrorMessage: "unauthorized"}}, nil
+	}
+	s.reqs <- req
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestOTLPPushGRPC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	svc := &testMetricsService{reqs: make(chan *colmetricspb.ExportMetricsServiceRequest, 1)}
+	colmetricspb.RegisterMetricsServiceServer(srv, svc)
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Stop()
+
+	e, _ := NewRedisExporter("redis://localhost:6379", Options{Namespace: "test", Registry: prometheus.NewRegistry(), ConnectionTimeouts: 100 * time.Millisecond})
+	p, err := NewOTLPPusher(OTLPConfig{Endpoint: "http://" + l.Addr().String(), Protocol: OTLPProtocolGRPC, Interval: time.Minute, Headers: map[string]string{"authorization": "Bearer xyz"}}, BuildInfo{})
+	if err != nil {
+		t.Fatalf("NewOTLPPusher() err: %s", err)
+	}
+	defer p.Close()
+	if err := p.Push(context.Background(), e); err != nil {
+		t.Fatalf("Push() err: %s", err)
+	}
+	checkOTLPRequest(t, <-svc.reqs)
+
+	p.cfg.Headers = nil
+	if err := p.Push(context.Background(), e); err == nil {
+		t.Errorf("expected an error for rejected data points")
+	}
+}
+
+func TestNewOTLPPusherInvalid(t *testing.T) {
+	for _, cfg := range []OTLPConfig{
+		{Endpoint: "collector:4318", Interval: time.Minute},
+		{Endpoint: "http://collector:4318", Interval: 0},
+		{Endpoint: "http://collector:4318", Interval: time.Minute, Protocol: "thrift"},
+	} {
+		if _, err := NewOTLPPusher(cfg, BuildInfo{}); err == nil {
+			t.Errorf("expected an error for %#v", cfg)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package exporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/mna/redisc"
+	log "github.com/sirupsen/logrus"
+)
+
+// stalePoolTimeout is how long the pool of an address whose connection settings changed
+// is kept if Options.ConnectionPoolIdleTimeout is 0
+const stalePoolTimeout = 5 * time.Minute
+
+// pingIdleConnsAfter is how long a pooled connection can be idle before it's checked
+// with a PING when it's taken from the pool
+const pingIdleConnsAfter = time.Minute
+
+// connPools holds the pools of authenticated connections by Redis address and the
+// fingerprint of the connection settings. It is shared by all exporters created from
+// the same Options, i.e. the targets of the config file and the per-request exporters
+// of the /scrape endpoint, which can connect to the same address with other credentials.
+type connPools struct {
+	sync.Mutex
+	pools map[string]*connPool // by address and fingerprint
+
+	// the pools of an address with another fingerprint are closed once they haven't been used for staleAfter
+	staleAfter time.Duration
+	now        func() time.Time
+}
+
+// connPool is either a pool of connections to a single instance or a cluster
+// with a pool per node
+type connPool struct {
+	addr     string
+	lastUsed time.Time
+	pool     *redis.Pool
+	cluster  *redisc.Cluster
+}
+
+func newConnPools(idleTimeout time.Duration) *connPools {
+	if idleTimeout <= 0 {
+		idleTimeout = stalePoolTimeout
+	}
+	return &connPools{pools: map[string]*connPool{}, staleAfter: idleTimeout, now: time.Now}
+}
+
+func (p *connPool) close() {
+	if p.pool != nil {
+		_ = p.pool.Close()
+	}
+	if p.cluster != nil {
+		_ = p.cluster.Close()
+	}
+}
+
+// close closes all pools, connections that are in use are closed once they are released
+func (p *connPools) close() {
+	p.Lock()
+	defer p.Unlock()
+	for key, cp := range p.pools {
+		cp.close()
+		delete(p.pools, key)
+	}
+}
+
+// get returns the pool for addr and fingerprint, it's created by newPool if there's none yet.
+// The pools of addr with other fingerprints that haven't been used for a while are closed,
+// e.g. the ones with a password that was rotated.
+func (p *connPools) get(addr string, fingerprint string, newPool func() (*connPool, error)) (*connPool, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	now := p.now()
+	key := addr + "\x00" + fingerprint
+	for k, cp := range p.pools {
+		if k != key && cp.addr == addr && now.Sub(cp.lastUsed) > p.staleAfter {
+			log.Infof("Closing the unused pooled connections of %s with old connection settings", redactURI(addr))
+			cp.close()
+			delete(p.pools, k)
+		}
+	}
+
+	cp, ok := p.pools[key]
+	if !ok {
+		var err error
+		if cp, err = newPool(); err != nil {
+			return nil, err
+		}
+		cp.addr = addr
+		p.pools[key] = cp
+	}
+	cp.lastUsed = now
+	return cp, nil
+}
+
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "<redacted>"
+	}
+	return u.Redacted()
+}
+
+// connFingerprint identifies the settings that the pooled connections were
+// authenticated and set up with, it changes when the credentials change, e.g. the
+// ones of the credential provider, or when one of the TLS files is replaced
+func (e *Exporter) connFingerprint(uri string) (string, error) {
+	user, password, err := e.credentials(uri)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t\x00%t\x00%t\x00%t\x00%t\x00",
+		user, password, e.options.ConnectionTimeouts, e.options.SkipTLSVerification, e.options.IsCluster, e.options.RESP3, e.options.SetClientName,
+		e.options.dialAllowlist != nil)
+
+	for _, file := range []string{e.options.ClientCertFile, e.options.ClientKeyFile, e.options.CaCertFile} {
+		if file == "" {
+			h.Write([]byte{0})
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", file, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newRedisPool returns a pool of connections created by dial, the ones that have been
+// idle for a while are checked with a PING before they are handed out
+func (e *Exporter) newRedisPool(dial func(ctx context.Context) (redis.Conn, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     e.options.ConnectionPoolMaxIdle,
+		IdleTimeout: e.options.ConnectionPoolIdleTimeout,
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			c, err := dial(ctx)
+			if err != nil {
+				return nil, err
+			}
+			e.setClientName(c)
+			return c, nil
+		},
+		TestOnBorrow: func(c redis.Conn, lastUsed time.Time) error {
+			if time.Since(lastUsed) < pingIdleConnsAfter {
+				return nil
+			}
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+}
+
+// getPooledConn returns a connection from the pool of the target, closing the
+// connection returns it to the pool unless it is broken
+func (e *Exporter) getPooledConn(ctx context.Context) (redis.Conn, error) {
+	uri := e.redisURI()
+	fingerprint, err := e.connFingerprint(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	cp, err := e.options.pools.get(uri, fingerprint, func() (*connPool, error) {
+		log.Debugf("Creating connection pool for %s", e.redisAddr)
+		return &connPool{pool: e.newRedisPool(e.connectToRedis)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cp.pool.GetContext(ctx)
+}
+
+// getPooledClusterConn returns a connection to the cluster of the target,
+// the cluster keeps a pool of connections per node
+func (e *Exporter) getPooledClusterConn() (redis.Conn, error) {
+	uri := e.redisURI()
+	fingerprint, err := e.connFingerprint(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	cp, err := e.options.pools.get("cluster+"+uri, fingerprint, func() (*connPool, error) {
+		cluster, err := e.newCluster(context.Background(), uri)
+		if err != nil {
+			return nil, err
+		}
+		cluster.CreatePool = func(addr string, options ...redis.DialOption) (*redis.Pool, error) {
+			log.Debugf("Creating connection pool for cluster node %s", addr)
+			return e.newRedisPool(func(ctx context.Context) (redis.Conn, error) {
+				return redis.DialContext(ctx, "tcp", addr, options...)
+			}), nil
+		}
+		log.Debugf("Running refresh on cluster object")
+		if err := cluster.Refresh(); err != nil {
+			_ = cluster.Close()
+			log.Errorf("Cluster refresh failed: %v", err)
+			return nil, fmt.Errorf("cluster refresh failed: %w", err)
+		}
+		return &connPool{cluster: cluster}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return retryClusterConn(cp.cluster.Get())
+}
@@ -0,0 +1,156 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
+)
+
+func TestConnPoolsGet(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	p := newConnPools(time.Minute)
+	p.now = func() time.Time { return now }
+	created := 0
+	newPool := func() (*connPool, error) {
+		created++
+		return &connPool{pool: &redis.Pool{}}, nil
+	}
+
+	first, _ := p.get("redis://localhost:6379", "a", newPool)
+	if same, _ := p.get("redis://localhost:6379", "a", newPool); same != first || created != 1 {
+		t.Errorf("expected the pool to be reused, created %d pools", created)
+	}
+
+	other, _ := p.get("redis://localhost:6379", "b", newPool)
+	if other == first || created != 2 {
+		t.Errorf("expected a new pool for another fingerprint, created %d pools", created)
+	}
+	if again, _ := p.get("redis://localhost:6379", "a", newPool); again != first || created != 2 {
+		t.Errorf("expected the pools of both fingerprints to be kept, created %d pools", created)
+	}
+
+	// a isn't used anymore, e.g. after its password was rotated
+	now = now.Add(45 * time.Second)
+	p.get("redis://localhost:6379", "b", newPool)
+	p.get("redis://localhost:6380", "a", newPool)
+	now = now.Add(30 * time.Second)
+	p.get("redis://localhost:6379", "b", newPool)
+	if _, err := first.pool.Get().Do("PING"); err == nil {
+		t.Errorf("expected the unused pool to be closed")
+	}
+	if len(p.pools) != 2 {
+		t.Errorf("expected the pools of b and the other address to be kept, got: %d", len(p.pools))
+	}
+
+	p.close()
+	if len(p.pools) != 0 {
+		t.Errorf("expected all pools to be removed, got: %d", len(p.pools))
+	}
+}
+
+func TestConnFingerprint(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "client.crt")
+	if err := os.WriteFile(certFile, []byte("cert"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e, _ := NewRedisExporter("redis://localhost:6379", Options{Namespace: "test", ClientCertFile: certFile})
+	uri := e.redisURI()
+
+	fp, err := e.connFingerprint(uri)
+	if err != nil {
+		t.Fatalf("connFingerprint() err: %s", err)
+	}
+	if again, _ := e.connFingerprint(uri); again != fp {
+		t.Errorf("expected the fingerprint to be stable")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	certChanged, _ := e.connFingerprint(uri)
+	if certChanged == fp {
+		t.Errorf("expected the fingerprint to change when the cert file changes")
+	}
+
+	e.options.PasswordMap = map[string]string{"redis://localhost:6379": "secret"}
+	pwdChanged, _ := e.connFingerprint(uri)
+	if pwdChanged == certChanged {
+		t.Errorf("expected the fingerprint to change when the password changes")
+	}
+
+	e.options.RESP3 = true
+	resp3, _ := e.connFingerprint(uri)
+	if resp3 == pwdChanged {
+		t.Errorf("expected the fingerprint to change with RESP3")
+	}
+
+	p := &rotatingProvider{current: "pwd1"}
+	e.options.CredentialProvider = p
+	provided, _ := e.connFingerprint(uri)
+	p.current = "pwd2"
+	p.Invalidate(uri)
+	if rotated, _ := e.connFingerprint(uri); provided == resp3 || rotated == provided {
+		t.Errorf("expected the fingerprint to change with the credentials of the provider")
+	}
+
+	_ = os.Remove(certFile)
+	if _, err := e.connFingerprint(uri); err == nil {
+		t.Errorf("expected an error for a missing cert file")
+	}
+}
+
+func TestConnectionPool(t *testing.T) {
+	s := exportertest.NewInfoServer(t)
+	addr := s.URI()
+	e, _ := NewRedisExporter(addr, Options{Namespace: "test", ConnectionPool: true, ConnectionPoolMaxIdle: 2, SetClientName: true})
+	defer e.Stop()
+
+	for i := 0; i < 3; i++ {
+		chM := make(chan prometheus.Metric)
+		go func() {
+			e.Collect(chM)
+			close(chM)
+		}()
+		for range chM {
+		}
+	}
+
+	if len(e.options.pools.pools) != 1 {
+		t.Fatalf("expected a connection pool for %s, got %d pools", addr, len(e.options.pools.pools))
+	}
+	for _, cp := range e.options.pools.pools {
+		if stats := cp.pool.Stats(); cp.addr != e.redisURI() || stats.ActiveCount != 1 || stats.IdleCount != 1 {
+			t.Errorf("expected the scrapes to share one connection to %s, got: %s %#v", e.redisURI(), cp.addr, stats)
+		}
+	}
+
+	setName, pings := 0, 0
+	for _, cmd := range s.Commands() {
+		switch strings.ToUpper(strings.Join(cmd[:min(2, len(cmd))], " ")) {
+		case "CLIENT SETNAME":
+			setName++
+		case "PING":
+			pings++
+		}
+	}
+	if setName != 1 {
+		t.Errorf("expected the client name to be set once on the pooled connection, got %d", setName)
+	}
+	if pings != 0 {
+		t.Errorf("expected no PING when recently used connections are taken from the pool, got %d", pings)
+	}
+
+	e.Stop()
+	if len(e.options.pools.pools) != 0 {
+		t.Errorf("expected Stop() to close the connection pools")
+	}
+}
@@ -0,0 +1,394 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+// recordingExt is the extension of the files with the recorded commands and replies of a Redis instance
+const recordingExt = ".resp"
+
+var recordingNameRE = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// recordingName returns the name of the file with the recording of the instance at addr, without credentials
+func recordingName(addr string) string {
+	name := addr
+	if u, err := url.Parse(addr); err == nil && u.Scheme != "" {
+		name = u.Host + u.Path
+	}
+	return strings.Trim(recordingNameRE.ReplaceAllString(name, "_"), "_") + recordingExt
+}
+
+// Recorder saves the commands the exporter sends to Redis together with the replies,
+// both RESP encoded, in one file per instance. Passwords in AUTH and HELLO commands
+// and the values of the config settings with secrets are redacted.
+type Recorder struct {
+	dir string
+
+	mtx   sync.Mutex
+	files map[string]*os.File
+}
+
+// NewRecorder returns a recorder that writes to dir, the directory is created if needed
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, files: map[string]*os.File{}}, nil
+}
+
+// Close closes the files of the recorder
+func (r *Recorder) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	var errs []error
+	for name, f := range r.files {
+		errs = append(errs, f.Close())
+		delete(r.files, name)
+	}
+	return errors.Join(errs...)
+}
+
+// record appends a command and its reply to the recording of the instance at addr
+func (r *Recorder) record(addr string, args []string, reply interface{}) {
+	args = redactCommand(args)
+	// the config command is often renamed, e.g. on managed Redis, so the reply of any
+	// "<command> GET <pattern>" is redacted, only the values of the secret settings are replaced
+	if len(args) == 3 && strings.EqualFold(args[1], "GET") {
+		reply = redactConfigReply(reply)
+	}
+	buf := appendRESPReply(appendRESPCommand(nil, args), reply)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	name := recordingName(addr)
+	f, ok := r.files[name]
+	if !ok {
+		var err error
+		if f, err = os.OpenFile(filepath.Join(r.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+			log.Errorf("Couldn't open recording %s, err: %s", name, err)
+			return
+		}
+		r.files[name] = f
+	}
+	if _, err := f.Write(buf); err != nil {
+		log.Errorf("Couldn't write recording %s, err: %s", name, err)
+	}
+}
+
+func redactCommand(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	redacted := append([]string(nil), args...)
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		for i := 1; i < len(redacted); i++ {
+			redacted[i] = "<redacted>"
+		}
+	case "HELLO":
+		// HELLO [protover [AUTH username password] [SETNAME clientname]]
+		for i := 1; i < len(redacted)-2; i++ {
+			if strings.EqualFold(redacted[i], "AUTH") {
+				redacted[i+2] = "<redacted>"
+			}
+		}
+	}
+	return redacted
+}
+
+func redactConfigReply(reply interface{}) interface{} {
+	values, ok := reply.([]interface{})
+	if !ok {
+		return reply
+	}
+	redacted := append([]interface{}(nil), values...)
+	for i := 0; i+1 < len(redacted); i += 2 {
+		if key, err := redis.String(redacted[i], nil); err == nil && redactedConfigKeys[key] {
+			redacted[i+1] = []byte("<redacted>")
+		}
+	}
+	return redacted
+}
+
+// wrap returns a connection that records the commands sent via c
+func (r *Recorder) wrap(addr string, c redis.Conn) redis.Conn {
+	return &recordingConn{Conn: c, r: r, addr: addr}
+}
+
+// recordingConn records the commands of Do and of Send with the reply returned by the matching Receive
+type recordingConn struct {
+	redis.Conn
+	r    *Recorder
+	addr string
+
+	mtx     sync.Mutex
+	pending [][]string
+}
+
+func commandArgs(cmd string, args []interface{}) []string {
+	s := make([]string, 0, len(args)+1)
+	s = append(s, cmd)
+	for _, arg := range args {
+		s = append(s, argString(arg))
+	}
+	return s
+}
+
+// done records the reply of a command, errors that aren't sent by Redis aren't recorded
+func (c *recordingConn) done(args []string, reply interface{}, err error) (interface{}, error) {
+	var redisErr redis.Error
+	switch {
+	case err == nil:
+		c.r.record(c.addr, args, reply)
+	case errors.As(err, &redisErr):
+		c.r.record(c.addr, args, redisErr)
+	}
+	return reply, err
+}
+
+func (c *recordingConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(cmd, args...)
+	return c.doDone(cmd, args, reply, err)
+}
+
+func (c *recordingConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	cc, ok := c.Conn.(redis.ConnWithContext)
+	if !ok {
+		// e.g. the retrying cluster connection, like for contextConn the read timeout still applies
+		return c.Do(cmd, args...)
+	}
+	reply, err := cc.DoContext(ctx, cmd, args...)
+	return c.doDone(cmd, args, reply, err)
+}
+
+func (c *recordingConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	cc, ok := c.Conn.(redis.ConnWithTimeout)
+	if !ok {
+		return c.Do(cmd, args...)
+	}
+	reply, err := cc.DoWithTimeout(timeout, cmd, args...)
+	return c.doDone(cmd, args, reply, err)
+}
+
+func (c *recordingConn) doDone(cmd string, args []interface{}, reply interface{}, err error) (interface{}, error) {
+	// Do returns the replies of the pending commands along with its own one, they aren't recorded
+	c.mtx.Lock()
+	c.pending = nil
+	c.mtx.Unlock()
+	if cmd == "" {
+		return reply, err
+	}
+	return c.done(commandArgs(cmd, args), reply, err)
+}
+
+func (c *recordingConn) Send(cmd string, args ...interface{}) error {
+	if err := c.Conn.Send(cmd, args...); err != nil {
+		return err
+	}
+	c.mtx.Lock()
+	c.pending = append(c.pending, commandArgs(cmd, args))
+	c.mtx.Unlock()
+	return nil
+}
+
+func (c *recordingConn) popPending() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.pending) == 0 {
+		return nil
+	}
+	args := c.pending[0]
+	c.pending = c.pending[1:]
+	return args
+}
+
+func (c *recordingConn) receiveDone(reply interface{}, err error) (interface{}, error) {
+	args := c.popPending()
+	if args == nil {
+		// e.g. a message of a subscription
+		return reply, err
+	}
+	return c.done(args, reply, err)
+}
+
+func (c *recordingConn) Receive() (interface{}, error) {
+	return c.receiveDone(c.Conn.Receive())
+}
+
+func (c *recordingConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	cc, ok := c.Conn.(redis.ConnWithContext)
+	if !ok {
+		return c.Receive()
+	}
+	return c.receiveDone(cc.ReceiveContext(ctx))
+}
+
+func (c *recordingConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	cc, ok := c.Conn.(redis.ConnWithTimeout)
+	if !ok {
+		return c.Receive()
+	}
+	return c.receiveDone(cc.ReceiveWithTimeout(timeout))
+}
+
+// Replay serves the replies of a recording of a Recorder instead of connecting to Redis.
+// A command gets the recorded replies of the same command in order, once they are used
+// up the last one is repeated, so a recording of one scrape can be replayed many times.
+type Replay struct {
+	mtx        sync.Mutex
+	recordings map[string]*recording
+}
+
+type recording struct {
+	replies map[string][]interface{} // by command
+	next    map[string]int
+}
+
+// LoadReplay loads the recordings in dir
+func LoadReplay(dir string) (*Replay, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+recordingExt))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recordings (*%s) found in %s", recordingExt, dir)
+	}
+
+	r := &Replay{recordings: map[string]*recording{}}
+	for _, file := range files {
+		rec, err := loadRecording(file)
+		if err != nil {
+			return nil, fmt.Errorf("recording %s: %w", file, err)
+		}
+		r.recordings[filepath.Base(file)] = rec
+	}
+	return r, nil
+}
+
+func loadRecording(file string) (*recording, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rec := &recording{replies: map[string][]interface{}{}, next: map[string]int{}}
+	br := bufio.NewReader(f)
+	for {
+		cmd, err := readRESP(br)
+		if errors.Is(err, io.EOF) {
+			return rec, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		values, ok := cmd.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a command, got: %#v", cmd)
+		}
+		args, _ := redis.Strings(values, nil)
+
+		reply, err := readRESP(br)
+		if err != nil {
+			return nil, fmt.Errorf("reply of %s: %w", strings.Join(args, " "), err)
+		}
+		key := replayKey(args)
+		rec.replies[key] = append(rec.replies[key], reply)
+	}
+}
+
+// replayKey identifies a command, the arguments of AUTH and HELLO are ignored
+// because they are redacted in the recording
+func replayKey(args []string) string {
+	if len(args) > 0 {
+		if cmd := strings.ToUpper(args[0]); cmd == "AUTH" || cmd == "HELLO" {
+			return cmd
+		}
+		args = append([]string{strings.ToUpper(args[0])}, args[1:]...)
+	}
+	return strings.Join(args, " ")
+}
+
+// conn returns a connection that serves the recording of the instance at addr,
+// or the only recording if there's just one
+func (r *Replay) conn(addr string) (redis.Conn, error) {
+	if rec, ok := r.recordings[recordingName(addr)]; ok {
+		return &replayConn{r: r, rec: rec}, nil
+	}
+	if len(r.recordings) == 1 {
+		for _, rec := range r.recordings {
+			return &replayConn{r: r, rec: rec}, nil
+		}
+	}
+	return nil, fmt.Errorf("no recording for %s", recordingName(addr))
+}
+
+func (r *Replay) reply(rec *recording, args []string) (interface{}, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	key := replayKey(args)
+	replies := rec.replies[key]
+	if len(replies) == 0 {
+		return nil, fmt.Errorf("no recorded reply for %q", key)
+	}
+	i := min(rec.next[key], len(replies)-1)
+	rec.next[key] = i + 1
+	if err, ok := replies[i].(redis.Error); ok {
+		return err, err
+	}
+	return replies[i], nil
+}
+
+// replayConn is the stand-in for a connection to Redis
+type replayConn struct {
+	r       *Replay
+	rec     *recording
+	pending [][]string
+}
+
+func (c *replayConn) Close() error { return nil }
+
+func (c *replayConn) Err() error { return nil }
+
+func (c *replayConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.pending = nil
+	if cmd == "" {
+		return nil, nil
+	}
+	return c.r.reply(c.rec, commandArgs(cmd, args))
+}
+
+func (c *replayConn) Send(cmd string, args ...interface{}) error {
+	c.pending = append(c.pending, commandArgs(cmd, args))
+	return nil
+}
+
+func (c *replayConn) Flush() error { return nil }
+
+func (c *replayConn) Receive() (interface{}, error) {
+	if len(c.pending) == 0 {
+		return nil, errors.New("no pending command to receive the reply of")
+	}
+	args := c.pending[0]
+	c.pending = c.pending[1:]
+	reply, err := c.r.reply(c.rec, args)
+	if err != nil {
+		// like redigo, Receive returns only the error
+		return nil, err
+	}
+	return reply, nil
+}
@@ -0,0 +1,141 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/oliver006/redis_exporter/exporter/exportertest"
+)
+
+func TestRESPRoundTrip(t *testing.T) {
+	for _, reply := range []interface{}{
+		"OK",
+		redis.Error("ERR unknown command"),
+		int64(-42),
+		[]byte("# Server\r\nredis_version:7.2.4\r\n"),
+		nil,
+		[]interface{}{},
+		[]interface{}{[]interface{}{int64(14), int64(1309448221), int64(15), []interface{}{[]byte("ping")}}, nil},
+	} {
+		got, err := readRESP(bufio.NewReader(bytes.NewReader(appendRESPReply(nil, reply))))
+		if err != nil || !reflect.DeepEqual(got, reply) {
+			t.Errorf("got: %#v, err: %v, want: %#v", got, err, reply)
+		}
+	}
+}
+
+func TestRecordingRedactsSecrets(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.record("redis://:secret@localhost:6379", []string{"AUTH", "user", "secret"}, "OK")
+	r.record("redis://:secret@localhost:6379", []string{"HELLO", "3", "AUTH", "user", "secret"}, []interface{}{})
+	r.record("redis://:secret@localhost:6379", []string{"CONFIG", "GET", "*"}, []interface{}{[]byte("requirepass"), []byte("secret"), []byte("maxmemory"), []byte("0")})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "localhost_6379.resp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "secret") {
+		t.Errorf("expected the secrets to be redacted:\n%s", content)
+	}
+
+	replay, err := LoadReplay(dir)
+	if err != nil {
+		t.Fatalf("LoadReplay() err: %s", err)
+	}
+	c, err := replay.conn("redis://localhost:6379")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := redis.String(c.Do("AUTH", "other", "password")); err != nil || reply != "OK" {
+		t.Errorf("AUTH: got %q, err: %v", reply, err)
+	}
+	if _, err := c.Do("PING"); err == nil {
+		t.Errorf("expected an error for a command that wasn't recorded")
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := exportertest.NewInfoServer(t)
+	s.Reply("SCAN", []interface{}{"0", []string{"key1"}})
+	s.Reply("TYPE", exportertest.Status("string"))
+	s.Reply("GET", "value")
+	s.Reply("STRLEN", int64(5))
+	s.Reply("MEMORY USAGE", int64(56))
+	e, _ := NewRedisExporter(s.URI(), Options{Namespace: "test", Recorder: recorder, CheckKeys: "db11=key*", InclMetricsForEmptyDatabases: true})
+	var live bytes.Buffer
+	if up, err := e.WriteMetrics(context.Background(), &live); err != nil || !up {
+		t.Fatalf("WriteMetrics() up: %t, err: %v", up, err)
+	}
+	if want := `test_key_size{db="db11",key="key1"} 5`; !strings.Contains(live.String(), want) {
+		t.Fatalf("didn't find %s in:\n%s", want, live.String())
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := LoadReplay(dir)
+	if err != nil {
+		t.Fatalf("LoadReplay() err: %s", err)
+	}
+	e, _ = NewRedisExporter("redis://unreachable:6379", Options{Namespace: "test", Replay: replay, CheckKeys: "db11=key*", InclMetricsForEmptyDatabases: true})
+	var replayed bytes.Buffer
+	if up, err := e.WriteMetrics(context.Background(), &replayed); err != nil || !up {
+		t.Fatalf("WriteMetrics() up: %t, err: %v", up, err)
+	}
+
+	// the durations differ between the runs
+	durations := regexp.MustCompile(`(?m)^test_.*(seconds|_sum)(\{.*\})? .*$`)
+	if got, want := durations.ReplaceAllString(replayed.String(), ""), durations.ReplaceAllString(live.String(), ""); got != want {
+		t.Errorf("replayed metrics differ, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRecordingRedactsRenamedConfigCommand(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := exportertest.NewInfoServer(t)
+	s.Reply("XCONFIG", []interface{}{"requirepass", "secret1", "masterauth", "secret2", "tls-key-file-pass", "secret3", "maxmemory", "1024"})
+	e, _ := NewRedisExporter(s.URI(), Options{Namespace: "test", Recorder: recorder, ConfigCommandName: "XCONFIG", InclConfigMetrics: true})
+	var buf bytes.Buffer
+	if _, err := e.WriteMetrics(context.Background(), &buf); err != nil {
+		t.Fatalf("WriteMetrics() err: %s", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, recordingName(s.URI())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "XCONFIG") || !strings.Contains(string(content), "maxmemory") {
+		t.Fatalf("expected the renamed config command to be recorded:\n%s", content)
+	}
+	if strings.Contains(string(content), "secret") {
+		t.Errorf("expected the secrets to be redacted:\n%s", content)
+	}
+}
@@ -1,7 +1,10 @@
 package exporter
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"strings"
 	"time"
@@ -17,6 +20,10 @@ func (e *Exporter) configureOptions(uri string) ([]redis.DialOption, error) {
 		return nil, err
 	}
 
+	if e.options.RESP3 {
+		return e.resp3DialOptions(uri, tlsConfig)
+	}
+
 	options := []redis.DialOption{
 		redis.DialConnectTimeout(e.options.ConnectionTimeouts),
 		redis.DialReadTimeout(e.options.ConnectionTimeouts),
@@ -24,22 +31,47 @@ func (e *Exporter) configureOptions(uri string) ([]redis.DialOption, error) {
 		redis.DialTLSConfig(tlsConfig),
 		redis.DialUseTLS(strings.HasPrefix(e.redisAddr, "rediss://")),
 	}
+	if e.options.dialAllowlist != nil {
+		options = append(options, redis.DialContextFunc(e.dialContext))
+	}
 
-	if e.options.User != "" {
-		options = append(options, redis.DialUsername(e.options.User))
+	user, password, err := e.credentials(uri)
+	if err != nil {
+		return nil, err
 	}
 
-	if e.options.Password != "" {
-		options = append(options, redis.DialPassword(e.options.Password))
+	if user != "" {
+		options = append(options, redis.DialUsername(user))
 	}
 
-	if pwd, ok := e.lookupPasswordInPasswordMap(uri); ok && pwd != "" {
-		options = append(options, redis.DialPassword(pwd))
+	if password != "" {
+		options = append(options, redis.DialPassword(password))
 	}
 
 	return options, nil
 }
 
+// credentials returns the user and password to authenticate with, the ones of the
+// credential provider take precedence over the password map and the options
+func (e *Exporter) credentials(uri string) (string, string, error) {
+	user, password := e.options.User, e.options.Password
+	if pwd, ok := e.lookupPasswordInPasswordMap(uri); ok {
+		password = pwd
+	}
+
+	if e.options.CredentialProvider != nil {
+		creds, err := e.options.CredentialProvider.Credentials(context.Background(), credentialsURI(uri))
+		if err != nil {
+			return "", "", fmt.Errorf("couldn't get credentials: %w", err)
+		}
+		if creds.Username != "" {
+			user = creds.Username
+		}
+		password = creds.Password
+	}
+	return user, password, nil
+}
+
 func (e *Exporter) lookupPasswordInPasswordMap(uri string) (string, bool) {
 	u, err := url.Parse(uri)
 	if err != nil {
@@ -55,48 +87,157 @@ func (e *Exporter) lookupPasswordInPasswordMap(uri string) (string, bool) {
 	uri = strings.Replace(uri, fmt.Sprintf(":@%s", u.Host), fmt.Sprintf("@%s", u.Host), 1)
 
 	log.Debugf("looking up in pwd map, uri: %s", uri)
-	if pwd, ok := e.options.PasswordMap[uri]; ok && pwd != "" {
+	e.RLock()
+	pwd, ok := e.options.PasswordMap[uri]
+	e.RUnlock()
+	if ok && pwd != "" {
 		return pwd, true
 	}
 	return "", false
 }
 
-func (e *Exporter) connectToRedis() (redis.Conn, error) {
+// redisURI returns the address of the target with a scheme
+func (e *Exporter) redisURI() string {
 	uri := e.redisAddr
 	if !strings.Contains(uri, "://") {
 		uri = "redis://" + uri
 	}
+	return uri
+}
+
+// getConn returns a connection to the target, taken from the connection pool if pooling is enabled
+func (e *Exporter) getConn(ctx context.Context) (redis.Conn, error) {
+	if e.options.Replay != nil {
+		return e.options.Replay.conn(e.redisAddr)
+	}
+	if e.options.pools != nil {
+		return e.recordConn(e.getPooledConn(ctx))
+	}
+	return e.recordConn(e.connectToRedis(ctx))
+}
+
+// recordConn wraps the connection so its commands are recorded if Options.Recorder is set
+func (e *Exporter) recordConn(c redis.Conn, err error) (redis.Conn, error) {
+	if err != nil || e.options.Recorder == nil {
+		return c, err
+	}
+	return e.options.Recorder.wrap(e.redisAddr, c), nil
+}
+
+// connectToRedis dials the target, connecting and authenticating stop when ctx is done
+func (e *Exporter) connectToRedis(ctx context.Context) (redis.Conn, error) {
+	c, authFailed, err := e.dialRedis(ctx)
+	if authFailed && e.options.CredentialProvider != nil {
+		log.Debugf("Authentication failed, refreshing the credentials")
+		e.options.CredentialProvider.Invalidate(credentialsURI(e.redisURI()))
+		c, _, err = e.dialRedis(ctx)
+	}
+	return c, err
+}
+
+// dialRedis connects to the target, authFailed is set when Redis rejected the credentials
+func (e *Exporter) dialRedis(ctx context.Context) (c redis.Conn, authFailed bool, err error) {
+	uri := e.redisURI()
 
 	options, err := e.configureOptions(uri)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if e.options.RESP3 {
+		uri = resp3DialURI(uri)
 	}
 
 	log.Debugf("Trying DialURL(): %s", uri)
-	c, err := redis.DialURL(uri, options...)
-	if err != nil {
+	c, err = redis.DialURLContext(ctx, uri, options...)
+	authFailed = isAuthError(err)
+	if err != nil && !(e.dialURLOnly && (strings.HasPrefix(uri, "redis://") || strings.HasPrefix(uri, "rediss://"))) {
 		log.Debugf("DialURL() failed, err: %s", err)
 		if frags := strings.Split(e.redisAddr, "://"); len(frags) == 2 {
 			log.Debugf("Trying: Dial(): %s %s", frags[0], frags[1])
-			c, err = redis.Dial(frags[0], frags[1], options...)
+			c, err = redis.DialContext(ctx, frags[0], frags[1], options...)
 		} else {
 			log.Debugf("Trying: Dial(): tcp %s", e.redisAddr)
-			c, err = redis.Dial("tcp", e.redisAddr, options...)
+			c, err = redis.DialContext(ctx, "tcp", e.redisAddr, options...)
 		}
 	}
-	return c, err
+	return c, authFailed || isAuthError(err), err
 }
 
-func (e *Exporter) connectToRedisCluster() (redis.Conn, error) {
-	uri := e.redisAddr
-	if !strings.Contains(uri, "://") {
-		uri = "redis://" + uri
+// dialContext connects to addr, only to the addresses in Options.dialAllowlist if it's set
+func (e *Exporter) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: e.options.ConnectionTimeouts, KeepAlive: 5 * time.Minute}
+	if e.options.dialAllowlist != nil {
+		return e.options.dialAllowlist.dial(ctx, d, network, addr)
+	}
+	return d.DialContext(ctx, network, addr)
+}
+
+// setClientName sets the name of the connection if Options.SetClientName is set, with RESP3 it's set by HELLO
+func (e *Exporter) setClientName(c redis.Conn) {
+	if !e.options.SetClientName || e.options.RESP3 {
+		return
+	}
+	if _, err := doRedisCmd(c, "CLIENT", "SETNAME", "redis_exporter"); err != nil {
+		log.Errorf("Couldn't set client name, err: %s", err)
 	}
+}
 
+// connectToRedisCluster returns a connection to the cluster of the target. The retrying
+// cluster connection can't be given the deadline of ctx per command, so unless it comes
+// from the pool, its connect, read and write timeouts are capped by the deadline instead.
+func (e *Exporter) connectToRedisCluster(ctx context.Context) (redis.Conn, error) {
+	if e.options.Replay != nil {
+		return e.options.Replay.conn(e.redisAddr)
+	}
+	if e.options.pools != nil {
+		return e.recordConn(e.getPooledClusterConn())
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cluster, err := e.newCluster(ctx, e.redisURI())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Running refresh on cluster object")
+	err = cluster.Refresh()
+	if isAuthError(err) && e.options.CredentialProvider != nil {
+		log.Debugf("Authentication failed, refreshing the credentials, err: %s", err)
+		e.options.CredentialProvider.Invalidate(credentialsURI(e.redisURI()))
+		if cluster, err = e.newCluster(ctx, e.redisURI()); err != nil {
+			return nil, err
+		}
+		err = cluster.Refresh()
+	}
+	if err != nil {
+		log.Errorf("Cluster refresh failed: %v", err)
+		return nil, fmt.Errorf("cluster refresh failed: %w", err)
+	}
+
+	log.Debugf("Creating redis connection object")
+	conn, err := cluster.Dial()
+	if err != nil {
+		log.Errorf("Dial failed: %v", err)
+		return nil, fmt.Errorf("dial failed: %w", err)
+	}
+
+	return e.recordConn(retryClusterConn(conn))
+}
+
+// newCluster returns the cluster of the target, the timeouts of its connections are
+// capped by the deadline of ctx
+func (e *Exporter) newCluster(ctx context.Context, uri string) (*redisc.Cluster, error) {
 	options, err := e.configureOptions(uri)
 	if err != nil {
 		return nil, err
 	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if to := max(time.Until(deadline), time.Millisecond); to < e.options.ConnectionTimeouts {
+			options = append(options, redis.DialConnectTimeout(to), redis.DialReadTimeout(to), redis.DialWriteTimeout(to))
+		}
+	}
 
 	// remove url scheme for redis.Cluster.StartupNodes
 	if strings.Contains(uri, "://") {
@@ -113,23 +254,13 @@ func (e *Exporter) connectToRedisCluster() (redis.Conn, error) {
 	}
 
 	log.Debugf("Creating cluster object")
-	cluster := redisc.Cluster{
+	return &redisc.Cluster{
 		StartupNodes: []string{uri},
 		DialOptions:  options,
-	}
-	log.Debugf("Running refresh on cluster object")
-	if err := cluster.Refresh(); err != nil {
-		log.Errorf("Cluster refresh failed: %v", err)
-		return nil, fmt.Errorf("cluster refresh failed: %w", err)
-	}
-
-	log.Debugf("Creating redis connection object")
-	conn, err := cluster.Dial()
-	if err != nil {
-		log.Errorf("Dial failed: %v", err)
-		return nil, fmt.Errorf("dial failed: %w", err)
-	}
+	}, nil
+}
 
+func retryClusterConn(conn redis.Conn) (redis.Conn, error) {
 	c, err := redisc.RetryConn(conn, 10, 100*time.Millisecond)
 	if err != nil {
 		log.Errorf("RetryConn failed: %v", err)
@@ -148,3 +279,67 @@ func doRedisCmd(c redis.Conn, cmd string, args ...interface{}) (interface{}, err
 	log.Debugf("c.Do() - done")
 	return res, err
 }
+
+// contextConn binds a context to a connection so all commands sent while running a
+// collector, including the ones sent by helpers that only get the connection, fail
+// once the context is done. The read timeout of a command is capped by the deadline.
+type contextConn struct {
+	redis.Conn
+	ctx context.Context
+}
+
+func withContext(ctx context.Context, c redis.Conn) redis.Conn {
+	return contextConn{Conn: c, ctx: ctx}
+}
+
+// deadlineErr turns a read timeout that was caused by the deadline of the context
+// into an error that wraps context.DeadlineExceeded
+func (c contextConn) deadlineErr(err error) error {
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		return err
+	}
+	if deadline, ok := c.ctx.Deadline(); ok && time.Until(deadline) < 10*time.Millisecond {
+		return fmt.Errorf("%w: %s", context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+func (c contextConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		return c.Conn.Do(cmd, args...)
+	}
+	switch cc := c.Conn.(type) {
+	case redis.ConnWithContext:
+		res, err := cc.DoContext(c.ctx, cmd, args...)
+		return res, c.deadlineErr(err)
+	case redis.ConnWithTimeout:
+		res, err := cc.DoWithTimeout(time.Until(deadline), cmd, args...)
+		return res, c.deadlineErr(err)
+	}
+	// e.g. the retrying cluster connection, its read timeout applies instead, see connectToRedisCluster
+	return c.Conn.Do(cmd, args...)
+}
+
+func (c contextConn) Receive() (interface{}, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		return c.Conn.Receive()
+	}
+	switch cc := c.Conn.(type) {
+	case redis.ConnWithContext:
+		res, err := cc.ReceiveContext(c.ctx)
+		return res, c.deadlineErr(err)
+	case redis.ConnWithTimeout:
+		res, err := cc.ReceiveWithTimeout(time.Until(deadline))
+		return res, c.deadlineErr(err)
+	}
+	return c.Conn.Receive()
+}
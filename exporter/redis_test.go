@@ -1,12 +1,16 @@
 package exporter
 
 import (
+	"context"
+	"net"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/gomodule/redigo/redis"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -15,7 +19,7 @@ func TestHostVariations(t *testing.T) {
 
 	for _, prefix := range []string{"", "redis://", "tcp://", ""} {
 		e, _ := NewRedisExporter(prefix+host, Options{SkipTLSVerification: true})
-		c, err := e.connectToRedis()
+		c, err := e.connectToRedis(context.Background())
 		if err != nil {
 			t.Errorf("connectToRedis() err: %s", err)
 			continue
@@ -33,7 +37,7 @@ func TestValkeyScheme(t *testing.T) {
 	host := os.Getenv("TEST_VALKEY8_URI")
 
 	e, _ := NewRedisExporter(host, Options{SkipTLSVerification: true})
-	c, err := e.connectToRedis()
+	c, err := e.connectToRedis(context.Background())
 	if err != nil {
 		t.Fatalf("connectToRedis() err: %s", err)
 	}
@@ -144,7 +148,7 @@ func TestConnectToClusterUsingPasswordFile(t *testing.T) {
 				PasswordMap:         tst.passMap,
 				IsCluster:           tst.isCluster,
 			})
-			_, err := e.connectToRedisCluster()
+			_, err := e.connectToRedisCluster(context.Background())
 			t.Logf("connectToRedisCluster() err: %s", err)
 			if err != nil && strings.Contains(err.Error(), "Cluster refresh failed:") && !tst.refreshError {
 				t.Fatalf("Test Cluster connection Failed error")
@@ -155,3 +159,39 @@ func TestConnectToClusterUsingPasswordFile(t *testing.T) {
 		})
 	}
 }
+
+func TestConnectStopsAtDeadline(t *testing.T) {
+	// accepts connections but never replies, so only the deadline ends the AUTH
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() err: %s", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	e, _ := NewRedisExporter("redis://"+l.Addr().String(), Options{Password: "pwd", ConnectionTimeouts: time.Minute})
+	for name, connect := range map[string]func(context.Context) (redis.Conn, error){
+		"single":  e.connectToRedis,
+		"cluster": e.connectToRedisCluster,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			if _, err := connect(ctx); err == nil {
+				t.Errorf("expected an error")
+			}
+			if took := time.Since(start); took > 5*time.Second {
+				t.Errorf("expected to give up at the deadline, took %s", took)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWriteConfig configures the push of the metrics with the Prometheus remote-write protocol
+type RemoteWriteConfig struct {
+	URL      string
+	Interval time.Duration
+	// Timeout limits the scrape and every single request to the receiver
+	Timeout time.Duration
+
+	Username    string
+	Password    string
+	BearerToken string
+
+	// MaxRetries is the number of retries of a failed request, with an exponential
+	// backoff between MinBackoff and MaxBackoff
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// QueueSize is the maximum number of scrapes that are kept in memory while the
+	// receiver is unavailable, the oldest ones are dropped first
+	QueueSize int
+
+	// ExternalLabels are added to all series like the external_labels of Prometheus,
+	// the labels of a series take precedence
+	ExternalLabels map[string]string
+}
+
+// RemoteWriter pushes the gathered metrics to a remote-write receiver on an interval
+type RemoteWriter struct {
+	cfg    RemoteWriteConfig
+	client *http.Client
+
+	// flushMtx makes sure the queued scrapes are sent once and in order
+	flushMtx sync.Mutex
+
+	mtx     sync.Mutex
+	queue   []*writeRequest // the scrapes that weren't sent yet
+	dropped int
+}
+
+// writeRequest is a snappy compressed remote-write request
+type writeRequest struct {
+	body []byte
+}
+
+// remoteWriteError is an error of a request that should not be retried
+type remoteWriteError struct {
+	err error
+}
+
+func (e remoteWriteError) Error() string { return e.err.Error() }
+
+// NewRemoteWriter returns a remote writer for the given config
+func NewRemoteWriter(cfg RemoteWriteConfig) (*RemoteWriter, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid remote-write URL %q", cfg.URL)
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("remote-write interval must be positive")
+	}
+	if cfg.BearerToken != "" && cfg.Username != "" {
+		return nil, fmt.Errorf("remote-write supports either basic auth or a bearer token, not both")
+	}
+	for name := range cfg.ExternalLabels {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return nil, fmt.Errorf("invalid remote-write external label name %q", name)
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = cfg.Interval
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = 100 * time.Millisecond
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1
+	}
+	return &RemoteWriter{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}, nil
+}
+
+// Run pushes the metrics of the exporter returned by exporter every interval until ctx is done
+func (w *RemoteWriter) Run(ctx context.Context, exporter func() *Exporter) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Push(ctx, exporter()); err != nil {
+			log.Errorf("Error pushing metrics via remote-write to %s, err: %s", w.cfg.URL, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push scrapes the exporter once, queues the result and sends all queued scrapes.
+// The series without an instance label get the address of the exporter's instance.
+func (w *RemoteWriter) Push(ctx context.Context, e *Exporter) error {
+	scrapeCtx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	gatherer, done, err := e.gatherer(scrapeCtx, nil)
+	if err != nil {
+		return err
+	}
+	mfs, err := gatherer.Gather()
+	done()
+	if err != nil {
+		log.Errorf("Error gathering metrics for remote-write, err: %s", err)
+	}
+	labels := map[string]string{}
+	if e.redisAddr != "" {
+		labels[instanceLabel] = targetInstance(e.redisAddr)
+	}
+	for name, value := range w.cfg.ExternalLabels {
+		labels[name] = value
+	}
+	w.enqueue(snappy.Encode(nil, encodeWriteRequest(mfs, time.Now(), labels)))
+	return w.flush(ctx)
+}
+
+func (w *RemoteWriter) enqueue(body []byte) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if len(w.queue) >= w.cfg.QueueSize {
+		w.queue = w.queue[1:]
+		w.dropped++
+		log.Warnf("Remote-write queue is full, dropped the oldest scrape (%d dropped so far)", w.dropped)
+	}
+	w.queue = append(w.queue, &writeRequest{body: body})
+}
+
+// remove removes req from the queue unless it was already dropped
+func (w *RemoteWriter) remove(req *writeRequest) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if i := slices.Index(w.queue, req); i >= 0 {
+		w.queue = slices.Delete(w.queue, i, i+1)
+	}
+}
+
+// flush sends the queued requests in order, it stops at the first request
+// that can't be sent after all retries and keeps it for the next flush.
+// The queue isn't locked while sending, so a scrape can be queued in the meantime.
+func (w *RemoteWriter) flush(ctx context.Context) error {
+	w.flushMtx.Lock()
+	defer w.flushMtx.Unlock()
+
+	w.mtx.Lock()
+	batch := slices.Clone(w.queue)
+	w.mtx.Unlock()
+
+	for i, req := range batch {
+		err := w.sendWithRetries(ctx, req.body)
+		if _, ok := err.(remoteWriteError); ok {
+			log.Errorf("Remote-write receiver rejected a scrape, dropping it, err: %s", err)
+		} else if err != nil {
+			return fmt.Errorf("%d scrapes queued: %w", len(batch)-i, err)
+		}
+		w.remove(req)
+	}
+	return nil
+}
+
+func (w *RemoteWriter) sendWithRetries(ctx context.Context, req []byte) error {
+	backoff := w.cfg.MinBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.send(ctx, req)
+		if _, ok := err.(remoteWriteError); err == nil || ok || attempt >= w.cfg.MaxRetries {
+			return err
+		}
+		log.Debugf("Remote-write failed, retrying in %s, err: %s", backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, w.cfg.MaxBackoff)
+	}
+}
+
+func (w *RemoteWriter) send(ctx context.Context, req []byte) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(req))
+	if err != nil {
+		return remoteWriteError{err}
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", "redis_exporter")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	switch {
+	case w.cfg.BearerToken != "":
+		httpReq.Header.Set("Authorization", "Bearer "+w.cfg.BearerToken)
+	case w.cfg.Username != "":
+		httpReq.SetBasicAuth(w.cfg.Username, w.cfg.Password)
+	}
+
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("remote-write receiver returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	// like Prometheus, only server errors and rate limiting are retried
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return remoteWriteError{err}
+}
+
+// encodeWriteRequest encodes the metrics as a remote-write WriteRequest protobuf message,
+// summaries and histograms are split into series like in the text exposition format.
+// The defaultLabels are added to the series that don't have them.
+func encodeWriteRequest(mfs []*dto.MetricFamily, now time.Time, defaultLabels map[string]string) []byte {
+	ts := now.UnixMilli()
+	var buf []byte
+	addSeries := func(name string, labels []*dto.LabelPair, val float64, extra ...string) {
+		lbls := make([][2]string, 0, len(labels)+len(defaultLabels)+2)
+		lbls = append(lbls, [2]string{"__name__", name})
+		for _, l := range labels {
+			lbls = append(lbls, [2]string{l.GetName(), l.GetValue()})
+		}
+		if len(extra) == 2 {
+			lbls = append(lbls, [2]string{extra[0], extra[1]})
+		}
+		for n, v := range defaultLabels {
+			if !slices.ContainsFunc(lbls, func(l [2]string) bool { return l[0] == n }) {
+				lbls = append(lbls, [2]string{n, v})
+			}
+		}
+		sort.Slice(lbls, func(i, j int) bool { return lbls[i][0] < lbls[j][0] })
+
+		var series []byte
+		for _, l := range lbls {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l[0])
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l[1])
+			series = protowire.AppendTag(series, 1, protowire.BytesType)
+			series = protowire.AppendBytes(series, label)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(val))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(ts))
+		series = protowire.AppendTag(series, 2, protowire.BytesType)
+		series = protowire.AppendBytes(series, sample)
+
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, series)
+	}
+
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				addSeries(name, m.GetLabel(), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				addSeries(name, m.GetLabel(), m.GetGauge().GetValue())
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().GetQuantile() {
+					addSeries(name, m.GetLabel(), q.GetValue(), "quantile", strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64))
+				}
+				addSeries(name+"_sum", m.GetLabel(), m.GetSummary().GetSampleSum())
+				addSeries(name+"_count", m.GetLabel(), float64(m.GetSummary().GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				hasInf := false
+				for _, b := range m.GetHistogram().GetBucket() {
+					hasInf = hasInf || math.IsInf(b.GetUpperBound(), 1)
+					addSeries(name+"_bucket", m.GetLabel(), float64(b.GetCumulativeCount()), "le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64))
+				}
+				if !hasInf {
+					addSeries(name+"_bucket", m.GetLabel(), float64(m.GetHistogram().GetSampleCount()), "le", "+Inf")
+				}
+				addSeries(name+"_sum", m.GetLabel(), m.GetHistogram().GetSampleSum())
+				addSeries(name+"_count", m.GetLabel(), float64(m.GetHistogram().GetSampleCount()))
+			default:
+				addSeries(name, m.GetLabel(), m.GetUntyped().GetValue())
+			}
+		}
+	}
+	return buf
+}
@@ -0,0 +1,240 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest decodes a remote-write WriteRequest into a map of
+// "name{label=value,...}" to the sample value
+func decodeWriteRequest(t *testing.T, buf []byte) map[string]float64 {
+	t.Helper()
+	series := map[string]float64{}
+	fields := func(b []byte, f func(num protowire.Number, typ protowire.Type, b []byte) int) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatalf("invalid tag: %d", n)
+			}
+			b = b[n:]
+			n = f(num, typ, b)
+			if n < 0 {
+				t.Fatalf("invalid field %d: %d", num, n)
+			}
+			b = b[n:]
+		}
+	}
+
+	fields(buf, func(_ protowire.Number, _ protowire.Type, b []byte) int {
+		ts, n := protowire.ConsumeBytes(b)
+		var labels []string
+		var name string
+		var val float64
+		fields(ts, func(num protowire.Number, _ protowire.Type, b []byte) int {
+			msg, n := protowire.ConsumeBytes(b)
+			switch num {
+			case 1:
+				var l [2]string
+				fields(msg, func(num protowire.Number, _ protowire.Type, b []byte) int {
+					s, n := protowire.ConsumeString(b)
+					l[num-1] = s
+					return n
+				})
+				if l[0] == "__name__" {
+					name = l[1]
+				} else {
+					labels = append(labels, l[0]+"="+l[1])
+				}
+			case 2:
+				fields(msg, func(num protowire.Number, typ protowire.Type, b []byte) int {
+					if num == 1 {
+						v, n := protowire.ConsumeFixed64(b)
+						val = math.Float64frombits(v)
+						return n
+					}
+					_, n := protowire.ConsumeVarint(b)
+					return n
+				})
+			}
+			return n
+		})
+		if !sort.StringsAreSorted(labels) {
+			t.Errorf("labels of %s aren't sorted: %v", name, labels)
+		}
+		series[name+"{"+strings.Join(labels, ",")+"}"] = val
+		return n
+	})
+	return series
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	r := prometheus.NewRegistry()
+	r.MustRegister(&constCollector{
+		prometheus.MustNewConstMetric(prometheus.NewDesc("test_up", "", nil, nil), prometheus.GaugeValue, 1),
+		prometheus.MustNewConstMetric(prometheus.NewDesc("test_commands_total", "", []string{"cmd"}, nil), prometheus.CounterValue, 42, "get"),
+		prometheus.MustNewConstSummary(prometheus.NewDesc("test_latency_percentiles_usec", "", []string{"cmd"}, nil),
+			10, 100, map[float64]float64{0.5: 5}, "get"),
+		prometheus.MustNewConstHistogram(prometheus.NewDesc("test_commands_latencies_usec", "", []string{"cmd"}, nil),
+			10, 100, map[float64]uint64{1: 2, 10: 7}, "get"),
+	})
+	mfs, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series := decodeWriteRequest(t, encodeWriteRequest(mfs, time.Now(), map[string]string{"cmd": "none", "env": "prod"}))
+	for name, want := range map[string]float64{
+		"test_up{cmd=none,env=prod}":                                    1,
+		"test_commands_total{cmd=get,env=prod}":                         42,
+		"test_latency_percentiles_usec{cmd=get,env=prod,quantile=0.5}":  5,
+		"test_latency_percentiles_usec_sum{cmd=get,env=prod}":           100,
+		"test_latency_percentiles_usec_count{cmd=get,env=prod}":         10,
+		"test_commands_latencies_usec_bucket{cmd=get,env=prod,le=1}":    2,
+		"test_commands_latencies_usec_bucket{cmd=get,env=prod,le=10}":   7,
+		"test_commands_latencies_usec_bucket{cmd=get,env=prod,le=+Inf}": 10,
+		"test_commands_latencies_usec_count{cmd=get,env=prod}":          10,
+	} {
+		if got, ok := series[name]; !ok || got != want {
+			t.Errorf("series %s: got %v (found: %t), want %v", name, got, ok, want)
+		}
+	}
+}
+
+func TestRemoteWritePush(t *testing.T) {
+	var mtx sync.Mutex
+	var statuses []int
+	var received []map[string]float64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" || r.Header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if user, pwd, ok := r.BasicAuth(); !ok || user != "user" || pwd != "pwd" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		if len(statuses) > 0 {
+			status := statuses[0]
+			statuses = statuses[1:]
+			http.Error(w, "unavailable", status)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		buf, err := snappy.Decode(nil, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = append(received, decodeWriteRequest(t, buf))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	e, _ := NewRedisExporter("redis://localhost:6379", Options{Namespace: "test", Registry: prometheus.NewRegistry(), ConnectionTimeouts: 100 * time.Millisecond})
+	w, err := NewRemoteWriter(RemoteWriteConfig{URL: ts.URL, Interval: time.Minute, Username: "user", Password: "pwd", MaxRetries: 2, MinBackoff: time.Millisecond, QueueSize: 2, ExternalLabels: map[string]string{"cluster": "prod"}})
+	if err != nil {
+		t.Fatalf("NewRemoteWriter() err: %s", err)
+	}
+
+	scrapesTotal := "test_exporter_scrapes_total{cluster=prod,instance=redis://localhost:6379}"
+
+	// two failures are retried
+	statuses = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	if err := w.Push(context.Background(), e); err != nil {
+		t.Fatalf("Push() err: %s", err)
+	}
+	up, ok := received[0]["test_up{cluster=prod,instance=redis://localhost:6379}"]
+	if len(received) != 1 || !ok || up != 0 || received[0][scrapesTotal] != 1 {
+		t.Fatalf("unexpected requests: %v", received)
+	}
+
+	// three pushes fail after all retries, the queue keeps only the last two
+	statuses = make([]int, 9)
+	for i := range statuses {
+		statuses[i] = http.StatusInternalServerError
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.Push(context.Background(), e); err == nil {
+			t.Fatalf("expected an error when the receiver is unavailable")
+		}
+	}
+	if err := w.Push(context.Background(), e); err != nil {
+		t.Fatalf("Push() err: %s", err)
+	}
+	if len(received) != 3 || received[1][scrapesTotal] != 4 || received[2][scrapesTotal] != 5 {
+		t.Errorf("expected the queued scrapes 4 and 5, got: %v", received[1:])
+	}
+
+	// client errors aren't retried
+	w.cfg.Password = "wrong"
+	if err := w.Push(context.Background(), e); err != nil {
+		t.Errorf("Push() err: %s", err)
+	}
+	if len(w.queue) != 0 {
+		t.Errorf("expected the rejected scrape to be dropped, queue: %d", len(w.queue))
+	}
+}
+
+func TestRemoteWriteEnqueueDuringFlush(t *testing.T) {
+	requests := make(chan struct{})
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		<-unblock
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	w, _ := NewRemoteWriter(RemoteWriteConfig{URL: ts.URL, Interval: time.Minute, QueueSize: 10})
+	w.enqueue([]byte("first"))
+	flushed := make(chan error)
+	go func() { flushed <- w.flush(context.Background()) }()
+	<-requests
+
+	queued := make(chan struct{})
+	go func() {
+		w.enqueue([]byte("second"))
+		close(queued)
+	}()
+	select {
+	case <-queued:
+	case <-time.After(time.Second):
+		t.Errorf("expected a scrape to be queued while the queue is sent")
+	}
+	close(unblock)
+	if err := <-flushed; err != nil {
+		t.Fatalf("flush() err: %s", err)
+	}
+	if len(w.queue) != 1 || string(w.queue[0].body) != "second" {
+		t.Errorf("expected only the scrape queued during the flush to be left, got %d", len(w.queue))
+	}
+}
+
+func TestNewRemoteWriterInvalid(t *testing.T) {
+	for _, cfg := range []RemoteWriteConfig{
+		{URL: "prometheus:9090/api/v1/write", Interval: time.Minute},
+		{URL: "http://prometheus:9090/api/v1/write", Interval: 0},
+		{URL: "http://prometheus:9090/api/v1/write", Interval: time.Minute, Username: "user", BearerToken: "xyz"},
+		{URL: "http://prometheus:9090/api/v1/write", Interval: time.Minute, ExternalLabels: map[string]string{"__name__": "x"}},
+		{URL: "http://prometheus:9090/api/v1/write", Interval: time.Minute, ExternalLabels: map[string]string{"a-b": "x"}},
+	} {
+		if _, err := NewRemoteWriter(cfg); err == nil {
+			t.Errorf("expected an error for %#v", cfg)
+		}
+	}
+}
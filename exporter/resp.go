@@ -0,0 +1,129 @@
+package exporter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// argString formats a command argument like redigo does when it sends the command
+func argString(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case []byte:
+		return string(arg)
+	case int:
+		return strconv.Itoa(arg)
+	case int64:
+		return strconv.FormatInt(arg, 10)
+	case float64:
+		return strconv.FormatFloat(arg, 'g', -1, 64)
+	case bool:
+		if arg {
+			return "1"
+		}
+		return "0"
+	case nil:
+		return ""
+	case redis.Argument:
+		return argString(arg.RedisArg())
+	default:
+		return fmt.Sprint(arg)
+	}
+}
+
+// appendRESPCommand appends a command as a RESP array of bulk strings
+func appendRESPCommand(buf []byte, args []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = appendRESPBulk(buf, arg)
+	}
+	return buf
+}
+
+func appendRESPBulk(buf []byte, s string) []byte {
+	buf = append(buf, '$')
+	buf = strconv.AppendInt(buf, int64(len(s)), 10)
+	buf = append(buf, '\r', '\n')
+	buf = append(buf, s...)
+	return append(buf, '\r', '\n')
+}
+
+// appendRESPReply appends a reply as returned by redigo in the RESP2 encoding it was received in
+func appendRESPReply(buf []byte, reply interface{}) []byte {
+	switch reply := reply.(type) {
+	case string:
+		return append(append(append(buf, '+'), reply...), '\r', '\n')
+	case redis.Error:
+		return append(append(append(buf, '-'), reply...), '\r', '\n')
+	case int64:
+		buf = append(buf, ':')
+		return append(strconv.AppendInt(buf, reply, 10), '\r', '\n')
+	case []byte:
+		return appendRESPBulk(buf, string(reply))
+	case []interface{}:
+		buf = append(buf, '*')
+		buf = strconv.AppendInt(buf, int64(len(reply)), 10)
+		buf = append(buf, '\r', '\n')
+		for _, r := range reply {
+			buf = appendRESPReply(buf, r)
+		}
+		return buf
+	case nil:
+		return append(buf, "$-1\r\n"...)
+	default:
+		// not returned by redigo, keep the value readable
+		return appendRESPBulk(buf, fmt.Sprint(reply))
+	}
+}
+
+// readRESP reads a single RESP2 value and returns it like redigo does
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid RESP line %q", line)
+	}
+	typ, val := line[0], line[1:len(line)-2]
+
+	switch typ {
+	case '+':
+		return val, nil
+	case '-':
+		return redis.Error(val), nil
+	case ':':
+		return strconv.ParseInt(val, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, errors.New("invalid RESP type " + strconv.Quote(string(typ)))
+}
@@ -0,0 +1,260 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// resp3Conn is a connection that speaks RESP3 with Redis and converts the replies
+// to the RESP2 types Redis sends to RESP2 clients for redigo, which only supports RESP2:
+// maps become flat arrays of keys and values, sets become arrays, doubles and big
+// numbers become bulk strings, booleans become integers and nulls become nil bulk
+// strings. Attributes and out of band push messages are dropped. This way the
+// parsers get the same replies and the metrics are the same as with RESP2.
+type resp3Conn struct {
+	net.Conn
+	r   *bufio.Reader
+	buf []byte // converted reply that wasn't read yet
+}
+
+func newRESP3Conn(c net.Conn) *resp3Conn {
+	return &resp3Conn{Conn: c, r: bufio.NewReader(c)}
+}
+
+func (c *resp3Conn) Read(p []byte) (int, error) {
+	if len(c.buf) == 0 {
+		buf, err := convertRESP3(c.r, c.buf[:0])
+		if err != nil {
+			return 0, err
+		}
+		c.buf = buf
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func readRESPLine(r *bufio.Reader) (byte, string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return 0, "", fmt.Errorf("invalid RESP line %q", line)
+	}
+	return line[0], line[1 : len(line)-2], nil
+}
+
+func readRESPBlob(r *bufio.Reader, length string) ([]byte, error) {
+	n, err := strconv.Atoi(length)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid RESP length %q", length)
+	}
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// convertRESP3 reads a RESP3 reply and appends it in RESP2 to out
+func convertRESP3(r *bufio.Reader, out []byte) ([]byte, error) {
+	for {
+		typ, val, err := readRESPLine(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case '+', '-', ':':
+			return append(append(append(out, typ), val...), '\r', '\n'), nil
+		case '$':
+			if val == "-1" {
+				return append(out, "$-1\r\n"...), nil
+			}
+			blob, err := readRESPBlob(r, val)
+			if err != nil {
+				return nil, err
+			}
+			return appendRESPBulk(out, string(blob)), nil
+		case '=':
+			// verbatim string, e.g. "txt:" followed by the text
+			blob, err := readRESPBlob(r, val)
+			if err != nil {
+				return nil, err
+			}
+			if len(blob) >= 4 && blob[3] == ':' {
+				blob = blob[4:]
+			}
+			return appendRESPBulk(out, string(blob)), nil
+		case '!':
+			blob, err := readRESPBlob(r, val)
+			if err != nil {
+				return nil, err
+			}
+			return append(append(append(out, '-'), strings.ReplaceAll(string(blob), "\r\n", " ")...), '\r', '\n'), nil
+		case ',', '(':
+			return appendRESPBulk(out, val), nil
+		case '#':
+			if val == "t" {
+				return append(out, ":1\r\n"...), nil
+			}
+			return append(out, ":0\r\n"...), nil
+		case '_':
+			return append(out, "$-1\r\n"...), nil
+		case '*', '~', '%':
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RESP length %q", val)
+			}
+			if n < 0 {
+				return append(out, "*-1\r\n"...), nil
+			}
+			if typ == '%' {
+				n *= 2
+			}
+			out = append(append(append(out, '*'), strconv.Itoa(n)...), '\r', '\n')
+			for i := 0; i < n; i++ {
+				if out, err = convertRESP3(r, out); err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		case '|', '>':
+			// attributes come before the reply they belong to, push messages
+			// are out of band, both are dropped
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid RESP length %q", val)
+			}
+			if typ == '|' {
+				n *= 2
+			}
+			for i := 0; i < n; i++ {
+				if _, err := convertRESP3(r, nil); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported RESP3 type %q", typ)
+		}
+	}
+}
+
+// resp3Credentials returns the user and password to authenticate with, like DialURL
+// the ones in the URI take precedence
+func (e *Exporter) resp3Credentials(uri string) (string, string, error) {
+	user, password, err := e.credentials(uri)
+	if err != nil {
+		return "", "", err
+	}
+	if u, err := url.Parse(uri); err == nil && u.User != nil {
+		if pwd, ok := u.User.Password(); ok {
+			if u.User.Username() != "" {
+				user = u.User.Username()
+			}
+			password = pwd
+		} else if u.User.Username() != "" {
+			password = u.User.Username()
+		}
+	}
+	return user, password, nil
+}
+
+// resp3DialOptions returns the options to connect with RESP3, the credentials
+// and TLS are handled by dialRESP3 instead of redigo
+func (e *Exporter) resp3DialOptions(uri string, tlsConfig *tls.Config) ([]redis.DialOption, error) {
+	user, password, err := e.resp3Credentials(uri)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(e.redisAddr, "rediss://") {
+		tlsConfig = nil
+	}
+	return []redis.DialOption{
+		redis.DialReadTimeout(e.options.ConnectionTimeouts),
+		redis.DialWriteTimeout(e.options.ConnectionTimeouts),
+		redis.DialContextFunc(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return e.dialRESP3(ctx, network, addr, tlsConfig, user, password)
+		}),
+	}, nil
+}
+
+// resp3DialURI removes the credentials and TLS from the URI so redigo doesn't
+// act on them, they're handled by dialRESP3
+func resp3DialURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	u.User = nil
+	if u.Scheme == "rediss" {
+		u.Scheme = "redis"
+	}
+	return u.String()
+}
+
+// dialRESP3 connects to Redis and negotiates RESP3 with HELLO 3, which also
+// authenticates the connection and sets the client name
+func (e *Exporter) dialRESP3(ctx context.Context, network, addr string, tlsConfig *tls.Config, user, password string) (net.Conn, error) {
+	c, err := e.dialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		cfg := tlsConfig.Clone()
+		if cfg.ServerName == "" {
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				cfg.ServerName = host
+			}
+		}
+		tlsConn := tls.Client(c, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = tlsConn
+	}
+
+	args := []string{"HELLO", "3"}
+	if password != "" {
+		if user == "" {
+			user = "default"
+		}
+		args = append(args, "AUTH", user, password)
+	}
+	if e.options.SetClientName {
+		args = append(args, "SETNAME", "redis_exporter")
+	}
+
+	if e.options.ConnectionTimeouts > 0 {
+		_ = c.SetDeadline(time.Now().Add(e.options.ConnectionTimeouts))
+	}
+	rc := newRESP3Conn(c)
+	if _, err := c.Write(appendRESPCommand(nil, args)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	reply, err := convertRESP3(rc.r, nil)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if reply[0] == '-' {
+		c.Close()
+		return nil, fmt.Errorf("couldn't negotiate RESP3: %w", redis.Error(strings.TrimSpace(string(reply[1:]))))
+	}
+	_ = c.SetDeadline(time.Time{})
+	return rc, nil
+}
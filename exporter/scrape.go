@@ -0,0 +1,179 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorNames are the built-in collectors, they can be selected via collect[] query parameters
+var collectorNames = []string{
+	"config",
+	"info",
+	"cluster_info",
+	"latency",
+	"check_keys",
+	"count_keys",
+	"streams",
+	"slowlog",
+	"key_groups",
+	"sentinel",
+	"client_list",
+	"tile38",
+	"modules",
+	"lua",
+}
+
+// backgroundCollectorNames are the collectors that can run in the background, see Options.BackgroundCollectors
+var backgroundCollectorNames = []string{
+	"check_keys",
+	"count_keys",
+	"key_groups",
+}
+
+// scrape collects the metrics of an exporter for a single request to the metrics or scrape endpoint
+type scrape struct {
+	ctx        context.Context
+	e          *Exporter
+	collectors map[string]bool
+}
+
+func (s *scrape) Describe(ch chan<- *prometheus.Desc) {
+	s.e.Describe(ch)
+}
+
+func (s *scrape) Collect(ch chan<- prometheus.Metric) {
+	s.e.collect(s.ctx, ch, s.collectors)
+}
+
+// parseCollectParams returns the collectors selected via collect[] query parameters,
+// nil if there are none
+func parseCollectParams(r *http.Request) (map[string]bool, error) {
+	params := r.URL.Query()["collect[]"]
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	collectors := map[string]bool{}
+	for _, name := range params {
+		if !slices.Contains(allCollectorNames(), name) {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		collectors[name] = true
+	}
+	return collectors, nil
+}
+
+// scrapeContext returns the context for a scrape, it ends when the request is cancelled or when
+// the scrape timeout sent by Prometheus (minus Options.ScrapeTimeoutOffset) is reached
+func (e *Exporter) scrapeContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		ctx, cancel := context.WithCancel(r.Context())
+		return ctx, cancel, nil
+	}
+
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return nil, nil, fmt.Errorf("invalid X-Prometheus-Scrape-Timeout-Seconds header %q", header)
+	}
+	timeout := time.Duration(seconds*float64(time.Second)) - e.options.ScrapeTimeoutOffset
+	if timeout <= 0 {
+		return nil, nil, fmt.Errorf("scrape timeout of %s is shorter than the scrape timeout offset of %s", header, e.options.ScrapeTimeoutOffset)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return ctx, cancel, nil
+}
+
+// ParseCollectorTimeouts parses a comma separated list of <collector>=<duration> pairs
+// like "key_groups=10s,check_keys=5s"
+func ParseCollectorTimeouts(s string) (map[string]time.Duration, error) {
+	return parseCollectorDurations(s, allCollectorNames(), "timeout")
+}
+
+// ParseBackgroundCollectors parses a comma separated list of <collector>=<interval> pairs
+// like "key_groups=10m,count_keys=5m", only the collectors that SCAN the keyspace
+// can run in the background
+func ParseBackgroundCollectors(s string) (map[string]time.Duration, error) {
+	intervals, err := parseCollectorDurations(s, backgroundCollectorNames, "interval")
+	if err != nil {
+		return nil, err
+	}
+	for name, d := range intervals {
+		if d <= 0 {
+			return nil, fmt.Errorf("interval for collector %s must be positive", name)
+		}
+	}
+	return intervals, nil
+}
+
+func parseCollectorDurations(s string, names []string, what string) (map[string]time.Duration, error) {
+	durations := map[string]time.Duration{}
+	if s == "" {
+		return durations, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid collector %s %q, expected <collector>=<duration>", what, pair)
+		}
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for collector %s: %w", what, name, err)
+		}
+		durations[name] = d
+	}
+	return durations, nil
+}
+
+// gatherer returns the metrics of a scrape of the exporter (or all its targets) within ctx, like
+// they are served on MetricsPath. It's Options.Registry, which the exporter is registered with, unless
+// the scrape is limited to collectors, then it's a registry of that scrape only. done must be called
+// when the gatherer was gathered.
+func (e *Exporter) gatherer(ctx context.Context, collectors map[string]bool) (gatherer prometheus.Gatherer, done func(), err error) {
+	if collectors == nil && e.options.Registry != nil {
+		return e.options.Registry, e.gathers.add(ctx), nil
+	}
+	registry := prometheus.NewRegistry()
+	if err := e.registerScrapes(ctx, registry, collectors); err != nil {
+		return nil, nil, err
+	}
+	return registry, func() {}, nil
+}
+
+// register registers the exporter (or all its targets) with registry
+func (e *Exporter) register(registry prometheus.Registerer) error {
+	if len(e.targets) == 0 {
+		return registry.Register(e)
+	}
+
+	for _, t := range e.targets {
+		if err := prometheus.WrapRegistererWith(t.labels, registry).Register(t.exporter); err != nil {
+			return fmt.Errorf("target %s: %w", t.labels[instanceLabel], err)
+		}
+	}
+	return nil
+}
+
+// registerScrapes registers the scrapes of the exporter (or all its targets) with the registry
+func (e *Exporter) registerScrapes(ctx context.Context, registry *prometheus.Registry, collectors map[string]bool) error {
+	if len(e.targets) == 0 {
+		return registry.Register(&scrape{ctx: ctx, e: e, collectors: collectors})
+	}
+
+	for _, t := range e.targets {
+		if err := prometheus.WrapRegistererWith(t.labels, registry).Register(&scrape{ctx: ctx, e: t.exporter, collectors: collectors}); err != nil {
+			return fmt.Errorf("target %s: %w", t.labels[instanceLabel], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package exporter
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeGroup de-duplicates overlapping scrapes, the callers of a scrape that's in flight
+// wait for it and all get the same metrics
+type scrapeGroup struct {
+	mtx     sync.Mutex
+	scrapes map[string]*sharedScrape
+}
+
+type sharedScrape struct {
+	ctx  *sharedContext
+	done chan struct{}
+
+	mtx     sync.Mutex
+	metrics []prometheus.Metric // the metrics collected so far
+}
+
+// collected returns the metrics that the scrape collected so far
+func (s *sharedScrape) collected() []prometheus.Metric {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return slices.Clone(s.metrics)
+}
+
+func newScrapeGroup() *scrapeGroup {
+	return &scrapeGroup{scrapes: map[string]*sharedScrape{}}
+}
+
+// do runs scrape for key unless a scrape for key is in flight, then it waits for that one.
+// The scrape runs with a context that is done once the contexts of all its callers are done,
+// so it isn't cut short when the first caller goes away or has the shortest deadline.
+// A caller whose ctx is done before the scrape gets the metrics collected so far and
+// complete is false.
+func (g *scrapeGroup) do(ctx context.Context, key string, scrape func(ctx context.Context, ch chan<- prometheus.Metric)) (metrics []prometheus.Metric, shared, complete bool) {
+	g.mtx.Lock()
+	s, shared := g.scrapes[key]
+	if !shared || !s.ctx.join(ctx) {
+		shared = false
+		s = &sharedScrape{ctx: newSharedContext(ctx), done: make(chan struct{})}
+		g.scrapes[key] = s
+		go g.run(key, s, scrape)
+	}
+	g.mtx.Unlock()
+
+	select {
+	case <-s.done:
+		return s.metrics, shared, true
+	case <-ctx.Done():
+		return s.collected(), shared, false
+	}
+}
+
+func (g *scrapeGroup) run(key string, s *sharedScrape, scrape func(ctx context.Context, ch chan<- prometheus.Metric)) {
+	ch := make(chan prometheus.Metric)
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for m := range ch {
+			s.mtx.Lock()
+			s.metrics = append(s.metrics, m)
+			s.mtx.Unlock()
+		}
+	}()
+	scrape(s.ctx, ch)
+	close(ch)
+	<-collected
+
+	g.mtx.Lock()
+	if g.scrapes[key] == s {
+		delete(g.scrapes, key)
+	}
+	g.mtx.Unlock()
+	s.ctx.release()
+	close(s.done)
+}
+
+// scrapeGroupKey returns the key of the shared scrape of the exporter with scrapeKey
+// and the collectors of collect[], requests with the same key share one scrape
+func scrapeGroupKey(scrapeKey string, collectors map[string]bool) string {
+	if collectors == nil {
+		return scrapeKey
+	}
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return scrapeKey + "\x00" + strings.Join(names, ",")
+}
+
+// sharedContext is the context of a shared scrape. It's done when the contexts of all the
+// callers are done and its deadline is the latest one of theirs.
+type sharedContext struct {
+	mtx        sync.Mutex
+	done       chan struct{}
+	err        error
+	pending    int
+	deadline   time.Time
+	noDeadline bool
+	stops      []func() bool
+}
+
+func newSharedContext(ctx context.Context) *sharedContext {
+	c := &sharedContext{done: make(chan struct{})}
+	c.join(ctx)
+	return c
+}
+
+// join adds the context of a caller, it returns false if c is already done
+func (c *sharedContext) join(ctx context.Context) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.err != nil {
+		return false
+	}
+
+	if d, ok := ctx.Deadline(); !ok {
+		c.noDeadline = true
+	} else if d.After(c.deadline) {
+		c.deadline = d
+	}
+	c.pending++
+	c.stops = append(c.stops, context.AfterFunc(ctx, func() {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		c.pending--
+		if c.pending == 0 && c.err == nil {
+			c.err = ctx.Err()
+			close(c.done)
+		}
+	}))
+	return true
+}
+
+// release stops watching the contexts of the callers once the scrape is done
+func (c *sharedContext) release() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, stop := range c.stops {
+		stop()
+	}
+	c.stops = nil
+}
+
+func (c *sharedContext) Deadline() (time.Time, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.noDeadline {
+		return time.Time{}, false
+	}
+	return c.deadline, true
+}
+
+func (c *sharedContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *sharedContext) Err() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.err
+}
+
+func (c *sharedContext) Value(interface{}) interface{} {
+	return nil
+}
+
+// gatherContexts are the contexts of the requests and pushes that are gathering Options.Registry,
+// the exporters registered with it scrape until all of them are done
+type gatherContexts struct {
+	mtx  sync.Mutex
+	next int
+	ctxs map[int]context.Context
+}
+
+func newGatherContexts() *gatherContexts {
+	return &gatherContexts{ctxs: map[int]context.Context{}}
+}
+
+// add adds the context of a gather, remove must be called when the gather is done
+func (g *gatherContexts) add(ctx context.Context) (remove func()) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	id := g.next
+	g.next++
+	g.ctxs[id] = ctx
+	return func() {
+		g.mtx.Lock()
+		defer g.mtx.Unlock()
+		delete(g.ctxs, id)
+	}
+}
+
+// context returns the context for a scrape during the gathers in flight, it's the background
+// context if there are none, e.g. when Options.Registry is gathered by the user of the exporter
+func (g *gatherContexts) context() (context.Context, func()) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	var c *sharedContext
+	for _, ctx := range g.ctxs {
+		if c == nil {
+			c = newSharedContext(ctx)
+		} else {
+			c.join(ctx)
+		}
+	}
+	if c == nil {
+		return context.Background(), func() {}
+	}
+	return c, c.release
+}
@@ -0,0 +1,134 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestScrapeGroupLongestDeadline(t *testing.T) {
+	g := newScrapeGroup()
+
+	started := make(chan struct{})
+	first, cancelFirst := context.WithTimeout(context.Background(), time.Minute)
+	second, cancelSecond := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelSecond()
+
+	var (
+		wg          sync.WaitGroup
+		scrapeErr   error
+		scrapes     int
+		gotDeadline time.Time
+	)
+	scrape := func(ctx context.Context, ch chan<- prometheus.Metric) {
+		scrapes++
+		close(started)
+		<-ctx.Done()
+		scrapeErr = ctx.Err()
+		gotDeadline, _ = ctx.Deadline()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.do(first, "a", scrape)
+	}()
+	<-started
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, shared, _ := g.do(second, "a", scrape); !shared {
+			t.Errorf("expected the second call to share the scrape")
+		}
+	}()
+	var s *sharedScrape
+	for {
+		g.mtx.Lock()
+		s = g.scrapes["a"]
+		pending := s.ctx.pending
+		g.mtx.Unlock()
+		if pending == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the scrape keeps running for the second caller when the first one goes away
+	cancelFirst()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-s.done:
+		t.Fatal("expected the scrape to run until the context of the second caller is done")
+	default:
+	}
+
+	cancelSecond()
+	wg.Wait()
+	<-s.done
+	if scrapes != 1 || scrapeErr != context.Canceled {
+		t.Errorf("expected one scrape that was canceled, got %d, err: %v", scrapes, scrapeErr)
+	}
+	if want, _ := second.Deadline(); !gotDeadline.Equal(want) {
+		t.Errorf("expected the deadline of the second caller %s, got %s", want, gotDeadline)
+	}
+	if len(g.scrapes) != 0 {
+		t.Errorf("expected no scrapes in flight, got %v", g.scrapes)
+	}
+}
+
+func TestScrapeGroupJoinedShorterDeadline(t *testing.T) {
+	g := newScrapeGroup()
+
+	desc := prometheus.NewDesc("test_metric", "test", nil, nil)
+	first := prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1)
+	second := prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 2)
+
+	sent := make(chan struct{})
+	finish := make(chan struct{})
+	scrape := func(ctx context.Context, ch chan<- prometheus.Metric) {
+		ch <- first
+		close(sent)
+		<-finish
+		ch <- second
+	}
+
+	type result struct {
+		metrics  []prometheus.Metric
+		complete bool
+	}
+	leader := make(chan result)
+	go func() {
+		// no deadline, the scrape runs until it's done
+		metrics, _, complete := g.do(context.Background(), "a", scrape)
+		leader <- result{metrics, complete}
+	}()
+	<-sent
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	metrics, shared, complete := g.do(ctx, "a", scrape)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("expected the joined caller to return at its own deadline, took %s", took)
+	}
+	if !shared || complete {
+		t.Errorf("expected an incomplete shared scrape, got shared: %t, complete: %t", shared, complete)
+	}
+	if len(metrics) != 1 || metrics[0] != first {
+		t.Errorf("expected the metrics collected so far, got %v", metrics)
+	}
+
+	close(finish)
+	select {
+	case r := <-leader:
+		if !r.complete || len(r.metrics) != 2 {
+			t.Errorf("expected the leader to get the complete scrape, got complete: %t, %d metrics", r.complete, len(r.metrics))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the leader to get the scrape once it's done")
+	}
+}
@@ -0,0 +1,105 @@
+package exporter
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseCollectParams(t *testing.T) {
+	for _, tst := range []struct {
+		query string
+		want  map[string]bool
+		ok    bool
+	}{
+		{query: "", want: nil, ok: true},
+		{query: "collect[]=info&collect[]=key_groups", want: map[string]bool{"info": true, "key_groups": true}, ok: true},
+		{query: "collect[]=info&collect[]=nope", ok: false},
+	} {
+		r := httptest.NewRequest("GET", "/metrics?"+tst.query, nil)
+		got, err := parseCollectParams(r)
+		if (err == nil) != tst.ok {
+			t.Errorf("query %q: got err: %v, want ok: %t", tst.query, err, tst.ok)
+			continue
+		}
+		if len(got) != len(tst.want) {
+			t.Errorf("query %q: got %v, want %v", tst.query, got, tst.want)
+		}
+		for k := range tst.want {
+			if !got[k] {
+				t.Errorf("query %q: missing collector %s", tst.query, k)
+			}
+		}
+	}
+}
+
+func TestScrapeContext(t *testing.T) {
+	e, _ := NewRedisExporter("", Options{Namespace: "test", ScrapeTimeoutOffset: 500 * time.Millisecond})
+
+	for _, tst := range []struct {
+		header       string
+		wantDeadline time.Duration
+		ok           bool
+	}{
+		{header: "", ok: true},
+		{header: "10", wantDeadline: 9500 * time.Millisecond, ok: true},
+		{header: "2.5", wantDeadline: 2 * time.Second, ok: true},
+		{header: "0.5", ok: false},
+		{header: "abc", ok: false},
+	} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if tst.header != "" {
+			r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tst.header)
+		}
+		ctx, cancel, err := e.scrapeContext(r)
+		if (err == nil) != tst.ok {
+			t.Errorf("header %q: got err: %v, want ok: %t", tst.header, err, tst.ok)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		deadline, hasDeadline := ctx.Deadline()
+		if tst.wantDeadline == 0 {
+			if hasDeadline {
+				t.Errorf("header %q: didn't expect a deadline", tst.header)
+			}
+		} else if remaining := time.Until(deadline); !hasDeadline || remaining > tst.wantDeadline || remaining < tst.wantDeadline-time.Second {
+			t.Errorf("header %q: got deadline in %s, want %s", tst.header, remaining, tst.wantDeadline)
+		}
+		cancel()
+	}
+}
+
+func TestParseCollectorTimeouts(t *testing.T) {
+	got, err := ParseCollectorTimeouts("key_groups=10s, check_keys=1500ms")
+	if err != nil {
+		t.Fatalf("ParseCollectorTimeouts() err: %s", err)
+	}
+	if got["key_groups"] != 10*time.Second || got["check_keys"] != 1500*time.Millisecond || len(got) != 2 {
+		t.Errorf("unexpected timeouts: %v", got)
+	}
+
+	for _, invalid := range []string{"key_groups", "nope=1s", "key_groups=abc"} {
+		if _, err := ParseCollectorTimeouts(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestParseBackgroundCollectors(t *testing.T) {
+	got, err := ParseBackgroundCollectors("key_groups=10m,count_keys=5m")
+	if err != nil {
+		t.Fatalf("ParseBackgroundCollectors() err: %s", err)
+	}
+	if got["key_groups"] != 10*time.Minute || got["count_keys"] != 5*time.Minute || len(got) != 2 {
+		t.Errorf("unexpected intervals: %v", got)
+	}
+
+	for _, invalid := range []string{"slowlog=1m", "key_groups=0s", "key_groups=abc"} {
+		if _, err := ParseBackgroundCollectors(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
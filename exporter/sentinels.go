@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"regexp"
 	"strconv"
 	"strings"
@@ -35,16 +36,19 @@ func (e *Exporter) handleMetricsSentinel(ch chan<- prometheus.Metric, fieldKey s
 	}
 }
 
-func (e *Exporter) extractSentinelMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+func (e *Exporter) extractSentinelMetrics(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn) error {
 	masterDetails, err := redis.Values(doRedisCmd(c, "SENTINEL", "MASTERS"))
 	if err != nil {
 		log.Debugf("Error getting sentinel master details %s:", err)
-		return
+		return err
 	}
 
 	log.Debugf("Sentinel master details: %#v", masterDetails)
 
 	for _, masterDetail := range masterDetails {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		masterDetailMap, err := redis.StringMap(masterDetail, nil)
 		if err != nil {
 			log.Debugf("Error getting masterDetailmap from masterDetail: %s, err: %s", masterDetail, err)
@@ -94,6 +98,7 @@ func (e *Exporter) extractSentinelMetrics(ch chan<- prometheus.Metric, c redis.C
 		log.Debugf("Slave details for master %s: %s", masterName, slaveDetails)
 		e.processSentinelSlaves(ch, slaveDetails, masterName, masterAddr)
 	}
+	return nil
 }
 
 func (e *Exporter) processSentinelSentinels(ch chan<- prometheus.Metric, sentinelDetails []interface{}, labels ...string) {
@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -116,7 +117,7 @@ func TestExtractSentinelMetricsForRedis(t *testing.T) {
 
 	chM := make(chan prometheus.Metric)
 	go func() {
-		e.extractSentinelMetrics(chM, c)
+		e.extractSentinelMetrics(context.Background(), chM, c)
 		close(chM)
 	}()
 
@@ -166,7 +167,7 @@ func TestExtractSentinelMetricsForSentinel(t *testing.T) {
 	chM := make(chan prometheus.Metric)
 	if strings.Contains(infoAll, "# Sentinel") {
 		go func() {
-			e.extractSentinelMetrics(chM, c)
+			e.extractSentinelMetrics(context.Background(), chM, c)
 			close(chM)
 		}()
 	} else {
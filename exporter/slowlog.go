@@ -5,24 +5,29 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-func (e *Exporter) extractSlowLogMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+func (e *Exporter) extractSlowLogMetrics(ch chan<- prometheus.Metric, c redis.Conn) error {
 	if reply, err := redis.Int64(doRedisCmd(c, "SLOWLOG", "LEN")); err == nil {
 		e.registerConstMetricGauge(ch, "slowlog_length", float64(reply))
 	}
 
 	values, err := redis.Values(doRedisCmd(c, "SLOWLOG", "GET", "1"))
 	if err != nil {
-		return
+		return err
 	}
+	e.extractSlowLogGetMetrics(ch, values)
+	return nil
+}
 
+// extractSlowLogGetMetrics exports the id and duration of the latest entry of a SLOWLOG GET reply
+func (e *Exporter) extractSlowLogGetMetrics(ch chan<- prometheus.Metric, values []interface{}) {
 	var slowlogLastID int64
 	var lastSlowExecutionDurationSeconds float64
 
 	if len(values) > 0 {
-		if values, err = redis.Values(values[0], err); err == nil && len(values) > 0 {
-			slowlogLastID = values[0].(int64)
-			if len(values) > 2 {
-				lastSlowExecutionDurationSeconds = float64(values[2].(int64)) / 1e6
+		if entry, err := redis.Values(values[0], nil); err == nil && len(entry) > 0 {
+			slowlogLastID = entry[0].(int64)
+			if len(entry) > 2 {
+				lastSlowExecutionDurationSeconds = float64(entry[2].(int64)) / 1e6
 			}
 		}
 	}
@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"strconv"
 	"strings"
 
@@ -171,29 +172,34 @@ func parseStreamItemId(id string) float64 {
 	return parsedId
 }
 
-func (e *Exporter) extractStreamMetrics(ch chan<- prometheus.Metric, c redis.Conn) {
+func (e *Exporter) extractStreamMetrics(ctx context.Context, ch chan<- prometheus.Metric, c redis.Conn) error {
 	streams, err := parseKeyArg(e.options.CheckStreams)
 	if err != nil {
 		log.Errorf("Couldn't parse given stream keys: %s", err)
-		return
+		return err
 	}
 
 	singleStreams, err := parseKeyArg(e.options.CheckSingleStreams)
 	if err != nil {
 		log.Errorf("Couldn't parse check-single-streams: %s", err)
-		return
+		return err
 	}
 	allStreams := append([]dbKeyPair{}, singleStreams...)
 
+	var lastErr error
 	scannedStreams, err := getKeysFromPatterns(c, streams, e.options.CheckKeysBatchSize)
 	if err != nil {
 		log.Errorf("Error expanding key patterns: %s", err)
+		lastErr = err
 	} else {
 		allStreams = append(allStreams, scannedStreams...)
 	}
 
 	log.Debugf("allStreams: %#v", allStreams)
 	for _, k := range allStreams {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if _, err := doRedisCmd(c, "SELECT", k.db); err != nil {
 			log.Debugf("Couldn't select database '%s' when getting stream info", k.db)
 			continue
@@ -201,6 +207,7 @@ func (e *Exporter) extractStreamMetrics(ch chan<- prometheus.Metric, c redis.Con
 		info, err := getStreamInfo(c, k.key)
 		if err != nil {
 			log.Errorf("couldn't get info for stream '%s', err: %s", k.key, err)
+			lastErr = err
 			continue
 		}
 		dbLabel := "db" + k.db
@@ -228,4 +235,5 @@ func (e *Exporter) extractStreamMetrics(ch chan<- prometheus.Metric, c redis.Con
 			}
 		}
 	}
+	return lastErr
 }
@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"os"
 	"strings"
 	"testing"
@@ -508,7 +509,7 @@ func TestStreamsExtractStreamMetrics(t *testing.T) {
 
 	chM := make(chan prometheus.Metric)
 	go func() {
-		e.extractStreamMetrics(chM, c)
+		e.extractStreamMetrics(context.Background(), chM, c)
 		close(chM)
 	}()
 	want := map[string]bool{
@@ -566,7 +567,7 @@ func TestStreamsExtractStreamMetricsExcludeConsumer(t *testing.T) {
 
 	chM := make(chan prometheus.Metric)
 	go func() {
-		e.extractStreamMetrics(chM, c)
+		e.extractStreamMetrics(context.Background(), chM, c)
 		close(chM)
 	}()
 	want := map[string]bool{
@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// instanceLabel is added to the metrics of every target when scraping a list of targets
+const instanceLabel = "instance"
+
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// target is the exporter of a single entry of Options.Targets
+type target struct {
+	exporter *Exporter
+	labels   prometheus.Labels
+}
+
+// createTargets creates an exporter for every entry of Options.Targets.
+// All metrics of a target are labeled with its address and the labels of the target,
+// targets without a given label get an empty value for it.
+func (e *Exporter) createTargets() error {
+	labelNames := map[string]bool{}
+	for _, t := range e.options.Targets {
+		for name := range t.Labels {
+			if name == instanceLabel {
+				return fmt.Errorf("target %s: label %q is reserved", targetInstance(t.Addr), instanceLabel)
+			}
+			if !labelNameRE.MatchString(name) {
+				return fmt.Errorf("target %s: invalid label name %q", targetInstance(t.Addr), name)
+			}
+			labelNames[name] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, t := range e.options.Targets {
+		if t.Addr == "" {
+			return fmt.Errorf("target without addr")
+		}
+		instance := targetInstance(t.Addr)
+		if seen[instance] {
+			return fmt.Errorf("duplicate target %s", instance)
+		}
+		seen[instance] = true
+		if err := t.validate(e.options.User); err != nil {
+			return fmt.Errorf("target %s: %w", instance, err)
+		}
+
+		child, err := NewRedisExporter(t.Addr, e.targetOptions(t))
+		if err != nil {
+			return fmt.Errorf("target %s: %w", instance, err)
+		}
+		// the targets are registered with Options.Registry of e and scrape during its gathers
+		child.gathers = e.gathers
+
+		labels := prometheus.Labels{instanceLabel: instance}
+		for name := range labelNames {
+			labels[name] = t.Labels[name]
+		}
+		e.targets = append(e.targets, target{exporter: child, labels: labels})
+		log.Debugf("Created target %s", instance)
+	}
+
+	// make sure the targets can be registered together, the metrics endpoint does that for every request
+	return e.registerScrapes(context.Background(), prometheus.NewRegistry(), nil)
+}
+
+// targetOptions returns the options for the exporter of a single target
+func (e *Exporter) targetOptions(t TargetConfig) Options {
+	opts := e.childOptions()
+	opts.Registry = nil
+
+	t.ConnectionConfig.apply(&opts)
+	t.ConnectionConfig.applyCredentialProvider(&opts)
+	return opts
+}
+
+// childOptions returns the options of e for an exporter of a single instance, without the
+// targets, modules and the options of the /scrape endpoint that only e serves
+func (e *Exporter) childOptions() Options {
+	opts := e.options
+	opts.Targets = nil
+	opts.Modules = nil
+	opts.ScrapeCacheSize = 0
+	opts.ScrapeCacheIdleTimeout = 0
+	opts.ScrapeMaxConcurrent = 0
+	opts.ScrapeOnePerTarget = false
+	opts.ScrapeRateLimit = 0
+	opts.ScrapeRateBurst = 0
+	opts.ScrapeTargetAllowlist = nil
+	opts.ScrapeKnownTargetsOnly = false
+	return opts
+}
+
+// targetInstance returns the address of a target without any credentials
+func targetInstance(addr string) string {
+	if u, err := url.Parse(addr); err == nil && u.User != nil {
+		u.User = nil
+		return u.String()
+	}
+	return addr
+}
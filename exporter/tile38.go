@@ -8,11 +8,11 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-func (e *Exporter) extractTile38Metrics(ch chan<- prometheus.Metric, c redis.Conn) {
+func (e *Exporter) extractTile38Metrics(ch chan<- prometheus.Metric, c redis.Conn) error {
 	info, err := redis.Strings(doRedisCmd(c, "SERVER", "EXT"))
 	if err != nil {
 		log.Errorf("extractTile38Metrics() err: %s", err)
-		return
+		return err
 	}
 
 	for i := 0; i < len(info); i += 2 {
@@ -30,4 +30,5 @@ func (e *Exporter) extractTile38Metrics(ch chan<- prometheus.Metric, c redis.Con
 
 		e.parseAndRegisterConstMetric(ch, fieldKey, fieldValue)
 	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"context"
 	"os"
 	"strings"
 	"testing"
@@ -58,7 +59,7 @@ func TestValkeyTLSScheme(t *testing.T) {
 					ClientKeyFile:       "../contrib/tls/redis.key",
 				},
 			)
-			c, err := e.connectToRedis()
+			c, err := e.connectToRedis(context.Background())
 			if err != nil {
 				t.Fatalf("connectToRedis() err: %s", err)
 			}
@@ -0,0 +1,252 @@
+package exporter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// the endpoints that can have their own credentials in the web config file
+const (
+	webEndpointMetrics = "metrics"
+	webEndpointScrape  = "scrape"
+	webEndpointAdmin   = "admin" // /-/reload and /discover-cluster-nodes
+)
+
+// WebConfig is the content of the file passed via --web.config.file, similar to the
+// web config of the Prometheus exporter-toolkit
+type WebConfig struct {
+	// WebAuthConfig is used for the endpoints that don't have their own section
+	WebAuthConfig `yaml:",inline"`
+
+	// Endpoints has the credentials of "metrics", "scrape" or "admin", they replace the global ones
+	Endpoints map[string]WebAuthConfig `yaml:"endpoints"`
+}
+
+// WebAuthConfig are the credentials accepted by an endpoint, requests need to
+// authenticate with one of them. An endpoint without credentials is open.
+type WebAuthConfig struct {
+	// BasicAuthUsers maps user names to bcrypt hashes of their passwords
+	BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+
+	// BearerTokenFiles are files that each contain a token for the Authorization: Bearer header
+	BearerTokenFiles []string `yaml:"bearer_token_files"`
+}
+
+func (c WebAuthConfig) isEmpty() bool {
+	return len(c.BasicAuthUsers) == 0 && len(c.BearerTokenFiles) == 0
+}
+
+// LoadWebConfig reads and validates a web config file
+func LoadWebConfig(path string) (*WebConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &WebConfig{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("couldn't parse web config file %s: %w", path, err)
+	}
+
+	auths := map[string]WebAuthConfig{"": cfg.WebAuthConfig}
+	for endpoint, auth := range cfg.Endpoints {
+		switch endpoint {
+		case webEndpointMetrics, webEndpointScrape, webEndpointAdmin:
+		default:
+			return nil, fmt.Errorf("web config file %s: unknown endpoint %q, expected metrics, scrape or admin", path, endpoint)
+		}
+		auths[endpoint] = auth
+	}
+	for _, auth := range auths {
+		for user, hash := range auth.BasicAuthUsers {
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return nil, fmt.Errorf("web config file %s: password of user %q isn't a bcrypt hash: %w", path, user, err)
+			}
+		}
+		for _, file := range auth.BearerTokenFiles {
+			if _, err := readBearerToken(file); err != nil {
+				return nil, fmt.Errorf("web config file %s: %w", path, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func readBearerToken(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read bearer token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("bearer token file %s is empty", file)
+	}
+	return token, nil
+}
+
+// WebAuth authenticates the requests to the endpoints of the exporter with the credentials
+// of a web config file. The file and the bearer token files are read again when they change,
+// if the web config file becomes invalid the previous one stays in use.
+type WebAuth struct {
+	path string
+
+	mtx     sync.Mutex
+	cfg     *WebConfig
+	modTime time.Time
+	size    int64
+	tokens  map[string]cachedToken // by file
+
+	// verified has the hashes of the credentials that matched, bcrypt is slow on purpose
+	verified sync.Map
+}
+
+type cachedToken struct {
+	token   string
+	modTime time.Time
+}
+
+// dummyHash is compared for unknown users so they take as long as known ones
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return hash
+})
+
+// NewWebAuth loads the web config file at path
+func NewWebAuth(path string) (*WebAuth, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadWebConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return &WebAuth{path: path, cfg: cfg, modTime: fi.ModTime(), size: fi.Size(), tokens: map[string]cachedToken{}}, nil
+}
+
+// config returns the current web config, it's reloaded if the file changed
+func (a *WebAuth) config() *WebConfig {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	fi, err := os.Stat(a.path)
+	if err != nil {
+		log.Errorf("Couldn't stat web config file %s, err: %s", a.path, err)
+		return a.cfg
+	}
+	if fi.ModTime().Equal(a.modTime) && fi.Size() == a.size {
+		return a.cfg
+	}
+
+	// the modification time is updated even if the file can't be loaded so the error is logged once
+	a.modTime, a.size = fi.ModTime(), fi.Size()
+	cfg, err := LoadWebConfig(a.path)
+	if err != nil {
+		log.Errorf("Error reloading web config file, keeping the previous one, err: %s", err)
+		return a.cfg
+	}
+	log.Infof("Reloaded web config file %s", a.path)
+	a.cfg = cfg
+	return cfg
+}
+
+// bearerToken returns the token in file, it's read again if the file changed
+func (a *WebAuth) bearerToken(file string) string {
+	fi, err := os.Stat(file)
+	if err != nil {
+		log.Errorf("Couldn't stat bearer token file %s, err: %s", file, err)
+		return ""
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if cached, ok := a.tokens[file]; ok && cached.modTime.Equal(fi.ModTime()) {
+		return cached.token
+	}
+	token, err := readBearerToken(file)
+	if err != nil {
+		log.Errorf("Error reading bearer token, err: %s", err)
+		return ""
+	}
+	a.tokens[file] = cachedToken{token: token, modTime: fi.ModTime()}
+	return token
+}
+
+// authConfig returns the credentials of endpoint
+func (a *WebAuth) authConfig(endpoint string) WebAuthConfig {
+	cfg := a.config()
+	if auth, ok := cfg.Endpoints[endpoint]; ok {
+		return auth
+	}
+	return cfg.WebAuthConfig
+}
+
+// authenticate checks the credentials of the request to endpoint, the returned
+// WWW-Authenticate challenge is set when it failed
+func (a *WebAuth) authenticate(r *http.Request, endpoint string) (challenge string, err error) {
+	auth := a.authConfig(endpoint)
+	if auth.isEmpty() {
+		return "", nil
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		for _, file := range auth.BearerTokenFiles {
+			if want := a.bearerToken(file); want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+				return "", nil
+			}
+		}
+	} else if user, password, ok := r.BasicAuth(); ok && a.verifyBasicAuthUser(auth, user, password) {
+		return "", nil
+	}
+
+	if len(auth.BasicAuthUsers) > 0 {
+		return `Basic realm="redis-exporter", charset="UTF-8"`, errors.New("Unauthorized")
+	}
+	return `Bearer realm="redis-exporter"`, errors.New("Unauthorized")
+}
+
+func (a *WebAuth) verifyBasicAuthUser(auth WebAuthConfig, user, password string) bool {
+	hash, ok := auth.BasicAuthUsers[user]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return false
+	}
+
+	key := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	if _, ok := a.verified.Load(key); ok {
+		return true
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false
+	}
+	a.verified.Store(key, struct{}{})
+	return true
+}
+
+// webEndpoint returns the endpoint of the web config file the request path belongs to
+func (e *Exporter) webEndpoint(path string) string {
+	switch path {
+	case e.options.MetricsPath:
+		return webEndpointMetrics
+	case "/scrape":
+		return webEndpointScrape
+	case "/-/reload", "/discover-cluster-nodes":
+		return webEndpointAdmin
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func bcryptHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// make sure the change is noticed even if it happens within the resolution of the modification time
+	future := time.Now().Add(time.Duration(len(content)) * time.Second)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebAuth(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	writeFile(t, tokenFile, "s3cr3t-token\n")
+	configFile := filepath.Join(dir, "web-config.yml")
+	writeFile(t, configFile, `
+basic_auth_users:
+  alice: `+bcryptHash(t, "alice-pwd")+`
+endpoints:
+  metrics:
+    bearer_token_files:
+      - `+tokenFile+`
+    basic_auth_users:
+      prometheus: `+bcryptHash(t, "prom-pwd")+`
+  admin:
+    basic_auth_users:
+      admin: `+bcryptHash(t, "admin-pwd")+`
+`)
+
+	webAuth, err := NewWebAuth(configFile)
+	if err != nil {
+		t.Fatalf("NewWebAuth() err: %s", err)
+	}
+	e, _ := NewRedisExporter("redis://127.0.0.1:1", Options{Namespace: "test", Registry: prometheus.NewRegistry(), WebAuth: webAuth})
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	type request struct {
+		path      string
+		user, pwd string
+		token     string
+		wantOK    bool
+	}
+	check := func(reqs []request) {
+		t.Helper()
+		for _, tst := range reqs {
+			req, _ := http.NewRequest("GET", ts.URL+tst.path, nil)
+			if tst.user != "" {
+				req.SetBasicAuth(tst.user, tst.pwd)
+			}
+			if tst.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tst.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if ok := resp.StatusCode != http.StatusUnauthorized; ok != tst.wantOK {
+				t.Errorf("%s user: %q pwd: %q token: %q, got status %d", tst.path, tst.user, tst.pwd, tst.token, resp.StatusCode)
+			}
+			if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("%s: expected a WWW-Authenticate header", tst.path)
+			}
+		}
+	}
+
+	check([]request{
+		{path: "/metrics", token: "s3cr3t-token", wantOK: true},
+		{path: "/metrics", user: "prometheus", pwd: "prom-pwd", wantOK: true},
+		{path: "/metrics", user: "prometheus", pwd: "prom-pwd", wantOK: true}, // cached
+		{path: "/metrics", user: "prometheus", pwd: "wrong"},
+		{path: "/metrics", user: "alice", pwd: "alice-pwd"},
+		{path: "/metrics", token: "wrong-token"},
+		{path: "/metrics"},
+		{path: "/scrape", user: "alice", pwd: "alice-pwd", wantOK: true},
+		{path: "/scrape", user: "prometheus", pwd: "prom-pwd"},
+		{path: "/scrape", token: "s3cr3t-token"},
+		{path: "/health", user: "alice", pwd: "alice-pwd", wantOK: true},
+		{path: "/health"},
+		{path: "/-/reload", user: "admin", pwd: "admin-pwd", wantOK: true},
+		{path: "/-/reload", user: "alice", pwd: "alice-pwd"},
+		{path: "/discover-cluster-nodes", user: "admin", pwd: "admin-pwd", wantOK: true},
+	})
+
+	// the token is rotated
+	writeFile(t, tokenFile, "new-token")
+	check([]request{
+		{path: "/metrics", token: "new-token", wantOK: true},
+		{path: "/metrics", token: "s3cr3t-token"},
+	})
+
+	// the config file is changed, /scrape is opened and alice is removed
+	writeFile(t, configFile, `
+basic_auth_users:
+  bob: `+bcryptHash(t, "bob-pwd")+`
+endpoints:
+  scrape: {}
+`)
+	check([]request{
+		{path: "/scrape", wantOK: true},
+		{path: "/health", user: "alice", pwd: "alice-pwd"},
+		{path: "/health", user: "bob", pwd: "bob-pwd", wantOK: true},
+		{path: "/metrics", user: "bob", pwd: "bob-pwd", wantOK: true},
+		{path: "/-/reload", user: "admin", pwd: "admin-pwd"},
+	})
+
+	// an invalid config file is ignored
+	writeFile(t, configFile, "basic_auth_users:\n  mallory: plain-text-password\n")
+	check([]request{
+		{path: "/health", user: "bob", pwd: "bob-pwd", wantOK: true},
+		{path: "/health", user: "mallory", pwd: "plain-text-password"},
+	})
+}
+
+func TestLoadWebConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	emptyToken := filepath.Join(dir, "empty-token")
+	writeFile(t, emptyToken, "\n")
+
+	for _, tst := range []struct {
+		content string
+		err     string
+	}{
+		{content: "basic_auth_users:\n  alice: secret\n", err: "isn't a bcrypt hash"},
+		{content: "endpoints:\n  probe:\n    basic_auth_users: {}\n", err: "unknown endpoint"},
+		{content: "bearer_token_files: [" + filepath.Join(dir, "missing") + "]\n", err: "couldn't read bearer token file"},
+		{content: "bearer_token_files: [" + emptyToken + "]\n", err: "is empty"},
+		{content: "tls_server_config:\n  cert_file: server.crt\n", err: "not found in type"},
+	} {
+		fn := filepath.Join(dir, "web-config.yml")
+		writeFile(t, fn, tst.content)
+		if _, err := LoadWebConfig(fn); err == nil || !strings.Contains(err.Error(), tst.err) {
+			t.Errorf("%q: expected %q, got: %v", tst.content, tst.err, err)
+		}
+	}
+}
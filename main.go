@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -56,58 +59,216 @@ func getEnvInt64(key string, defaultVal int64) int64 {
 	return defaultVal
 }
 
+// flagEnvVars maps flag names to the environment variable that can be used to set them
+var flagEnvVars = map[string]string{}
+
+func stringFlag(name string, envVar string, defaultVal string, usage string) *string {
+	flagEnvVars[name] = envVar
+	return flag.String(name, getEnv(envVar, defaultVal), usage)
+}
+
+func boolFlag(name string, envVar string, defaultVal bool, usage string) *bool {
+	flagEnvVars[name] = envVar
+	return flag.Bool(name, getEnvBool(envVar, defaultVal), usage)
+}
+
+func int64Flag(name string, envVar string, defaultVal int64, usage string) *int64 {
+	flagEnvVars[name] = envVar
+	return flag.Int64(name, getEnvInt64(envVar, defaultVal), usage)
+}
+
+// pinnedFlags returns the flags that were set on the command line or via
+// their environment variable, the config file can't override those
+func pinnedFlags() map[string]bool {
+	pinned := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		pinned[f.Name] = true
+	})
+	for name, envVar := range flagEnvVars {
+		if _, ok := os.LookupEnv(envVar); ok {
+			pinned[name] = true
+		}
+	}
+	return pinned
+}
+
+// startupOnlyFlags are only read at startup, a reload of the config file keeps their values
+var startupOnlyFlags = []string{
+	"web.listen-address", "web.config.file", "tls-server-*", "log-format", "debug",
+	"record", "replay", "redis.credential-command", "otlp.*", "remote-write.*",
+}
+
+func isStartupOnlyFlag(name string) bool {
+	for _, f := range startupOnlyFlags {
+		if prefix, ok := strings.CutSuffix(f, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConfigFile resets all flags that aren't pinned to their defaults and then
+// sets them to the values from the config file. On a reload the startup-only
+// flags are left alone and changes to them are logged.
+// The returned func restores the flag values from before the call.
+func applyConfigFile(configFile string, pinned map[string]bool, reloading bool) (*exporter.Config, func(), error) {
+	cfg, err := exporter.LoadConfigFile(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name := range cfg.Settings {
+		if name == "config.file" || name == "version" || flag.Lookup(name) == nil {
+			return nil, nil, fmt.Errorf("unknown setting %q in config file %s", name, configFile)
+		}
+	}
+
+	keep := func(name string) bool {
+		return pinned[name] || name == "config.file" || reloading && isStartupOnlyFlag(name)
+	}
+
+	prev := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		if !keep(f.Name) {
+			prev[f.Name] = f.Value.String()
+		}
+	})
+	restore := func() {
+		for name, val := range prev {
+			_ = flag.Set(name, val)
+		}
+	}
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if !keep(f.Name) {
+			_ = flag.Set(f.Name, f.DefValue)
+		}
+	})
+	for name, val := range cfg.Settings {
+		if pinned[name] {
+			log.Debugf("Ignoring setting %s from config file, it is set via flag or environment variable", name)
+			continue
+		}
+		if keep(name) {
+			if string(val) != flag.Lookup(name).Value.String() {
+				log.Warnf("Ignoring the changed setting %s in config file %s, it only takes effect after a restart", name, configFile)
+			}
+			continue
+		}
+		if err := flag.Set(name, string(val)); err != nil {
+			restore()
+			return nil, nil, fmt.Errorf("invalid value %q for setting %s in config file %s: %s", val, name, configFile, err)
+		}
+	}
+	return cfg, restore, nil
+}
+
 func main() {
 	var (
-		redisAddr                      = flag.String("redis.addr", getEnv("REDIS_ADDR", "redis://localhost:6379"), "Address of the Redis instance to scrape")
-		redisUser                      = flag.String("redis.user", getEnv("REDIS_USER", ""), "User name to use for authentication (Redis ACL for Redis 6.0 and newer)")
-		redisPwd                       = flag.String("redis.password", getEnv("REDIS_PASSWORD", ""), "Password of the Redis instance to scrape")
-		redisPwdFile                   = flag.String("redis.password-file", getEnv("REDIS_PASSWORD_FILE", ""), "Password file of the Redis instance to scrape")
-		namespace                      = flag.String("namespace", getEnv("REDIS_EXPORTER_NAMESPACE", "redis"), "Namespace for metrics")
-		checkKeys                      = flag.String("check-keys", getEnv("REDIS_EXPORTER_CHECK_KEYS", ""), "Comma separated list of key-patterns to export value and length/size, searched for with SCAN")
-		checkSingleKeys                = flag.String("check-single-keys", getEnv("REDIS_EXPORTER_CHECK_SINGLE_KEYS", ""), "Comma separated list of single keys to export value and length/size")
-		checkKeyGroups                 = flag.String("check-key-groups", getEnv("REDIS_EXPORTER_CHECK_KEY_GROUPS", ""), "Comma separated list of lua regex for grouping keys")
-		checkStreams                   = flag.String("check-streams", getEnv("REDIS_EXPORTER_CHECK_STREAMS", ""), "Comma separated list of stream-patterns to export info about streams, groups and consumers, searched for with SCAN")
-		checkSingleStreams             = flag.String("check-single-streams", getEnv("REDIS_EXPORTER_CHECK_SINGLE_STREAMS", ""), "Comma separated list of single streams to export info about streams, groups and consumers")
-		streamsExcludeConsumerMetrics  = flag.Bool("streams-exclude-consumer-metrics", getEnvBool("REDIS_EXPORTER_STREAMS_EXCLUDE_CONSUMER_METRICS", false), "Don't collect per consumer metrics for streams (decreases cardinality)")
-		countKeys                      = flag.String("count-keys", getEnv("REDIS_EXPORTER_COUNT_KEYS", ""), "Comma separated list of patterns to count (eg: 'db0=production_*,db3=sessions:*'), searched for with SCAN")
-		checkKeysBatchSize             = flag.Int64("check-keys-batch-size", getEnvInt64("REDIS_EXPORTER_CHECK_KEYS_BATCH_SIZE", 1000), "Approximate number of keys to process in each execution, larger value speeds up scanning.\nWARNING: Still Redis is a single-threaded app, huge COUNT can affect production environment.")
-		scriptPath                     = flag.String("script", getEnv("REDIS_EXPORTER_SCRIPT", ""), "Comma separated list of path(s) to Redis Lua script(s) for gathering extra metrics")
-		listenAddress                  = flag.String("web.listen-address", getEnv("REDIS_EXPORTER_WEB_LISTEN_ADDRESS", ":9121"), "Address to listen on for web interface and telemetry.")
-		metricPath                     = flag.String("web.telemetry-path", getEnv("REDIS_EXPORTER_WEB_TELEMETRY_PATH", "/metrics"), "Path under which to expose metrics.")
-		logFormat                      = flag.String("log-format", getEnv("REDIS_EXPORTER_LOG_FORMAT", "txt"), "Log format, valid options are txt and json")
-		configCommand                  = flag.String("config-command", getEnv("REDIS_EXPORTER_CONFIG_COMMAND", "CONFIG"), "What to use for the CONFIG command, set to \"-\" to skip config metrics extraction")
-		connectionTimeout              = flag.String("connection-timeout", getEnv("REDIS_EXPORTER_CONNECTION_TIMEOUT", "15s"), "Timeout for connection to Redis instance")
-		tlsClientKeyFile               = flag.String("tls-client-key-file", getEnv("REDIS_EXPORTER_TLS_CLIENT_KEY_FILE", ""), "Name of the client key file (including full path) if the server requires TLS client authentication")
-		tlsClientCertFile              = flag.String("tls-client-cert-file", getEnv("REDIS_EXPORTER_TLS_CLIENT_CERT_FILE", ""), "Name of the client certificate file (including full path) if the server requires TLS client authentication")
-		tlsCaCertFile                  = flag.String("tls-ca-cert-file", getEnv("REDIS_EXPORTER_TLS_CA_CERT_FILE", ""), "Name of the CA certificate file (including full path) if the server requires TLS client authentication")
-		tlsServerKeyFile               = flag.String("tls-server-key-file", getEnv("REDIS_EXPORTER_TLS_SERVER_KEY_FILE", ""), "Name of the server key file (including full path) if the web interface and telemetry should use TLS")
-		tlsServerCertFile              = flag.String("tls-server-cert-file", getEnv("REDIS_EXPORTER_TLS_SERVER_CERT_FILE", ""), "Name of the server certificate file (including full path) if the web interface and telemetry should use TLS")
-		tlsServerCaCertFile            = flag.String("tls-server-ca-cert-file", getEnv("REDIS_EXPORTER_TLS_SERVER_CA_CERT_FILE", ""), "Name of the CA certificate file (including full path) if the web interface and telemetry should require TLS client authentication")
-		tlsServerMinVersion            = flag.String("tls-server-min-version", getEnv("REDIS_EXPORTER_TLS_SERVER_MIN_VERSION", "TLS1.2"), "Minimum TLS version that is acceptable by the web interface and telemetry when using TLS")
-		maxDistinctKeyGroups           = flag.Int64("max-distinct-key-groups", getEnvInt64("REDIS_EXPORTER_MAX_DISTINCT_KEY_GROUPS", 100), "The maximum number of distinct key groups with the most memory utilization to present as distinct metrics per database, the leftover key groups will be aggregated in the 'overflow' bucket")
-		isDebug                        = flag.Bool("debug", getEnvBool("REDIS_EXPORTER_DEBUG", false), "Output verbose debug information")
-		setClientName                  = flag.Bool("set-client-name", getEnvBool("REDIS_EXPORTER_SET_CLIENT_NAME", true), "Whether to set client name to redis_exporter")
-		isTile38                       = flag.Bool("is-tile38", getEnvBool("REDIS_EXPORTER_IS_TILE38", false), "Whether to scrape Tile38 specific metrics")
-		isCluster                      = flag.Bool("is-cluster", getEnvBool("REDIS_EXPORTER_IS_CLUSTER", false), "Whether this is a redis cluster (Enable this if you need to fetch key level data on a Redis Cluster).")
-		exportClientList               = flag.Bool("export-client-list", getEnvBool("REDIS_EXPORTER_EXPORT_CLIENT_LIST", false), "Whether to scrape Client List specific metrics")
-		exportClientPort               = flag.Bool("export-client-port", getEnvBool("REDIS_EXPORTER_EXPORT_CLIENT_PORT", false), "Whether to include the client's port when exporting the client list. Warning: including the port increases the number of metrics generated and will make your Prometheus server take up more memory")
+		configFile                     = stringFlag("config.file", "REDIS_EXPORTER_CONFIG_FILE", "", "Path to a YAML or JSON file with settings, keys are the command line flag names. Flags and environment variables take precedence")
+		redisAddr                      = stringFlag("redis.addr", "REDIS_ADDR", "redis://localhost:6379", "Address of the Redis instance to scrape")
+		redisUser                      = stringFlag("redis.user", "REDIS_USER", "", "User name to use for authentication (Redis ACL for Redis 6.0 and newer)")
+		redisPwd                       = stringFlag("redis.password", "REDIS_PASSWORD", "", "Password of the Redis instance to scrape")
+		redisPwdFile                   = stringFlag("redis.password-file", "REDIS_PASSWORD_FILE", "", "Password file of the Redis instance to scrape")
+		redisCredentialCommand         = stringFlag("redis.credential-command", "REDIS_CREDENTIAL_COMMAND", "", "Command that prints the credentials of the Redis instance as JSON, it's run with the URI of the instance as the last argument")
+		namespace                      = stringFlag("namespace", "REDIS_EXPORTER_NAMESPACE", "redis", "Namespace for metrics")
+		checkKeys                      = stringFlag("check-keys", "REDIS_EXPORTER_CHECK_KEYS", "", "Comma separated list of key-patterns to export value and length/size, searched for with SCAN")
+		checkSingleKeys                = stringFlag("check-single-keys", "REDIS_EXPORTER_CHECK_SINGLE_KEYS", "", "Comma separated list of single keys to export value and length/size")
+		checkKeyGroups                 = stringFlag("check-key-groups", "REDIS_EXPORTER_CHECK_KEY_GROUPS", "", "Comma separated list of lua regex for grouping keys")
+		checkStreams                   = stringFlag("check-streams", "REDIS_EXPORTER_CHECK_STREAMS", "", "Comma separated list of stream-patterns to export info about streams, groups and consumers, searched for with SCAN")
+		checkSingleStreams             = stringFlag("check-single-streams", "REDIS_EXPORTER_CHECK_SINGLE_STREAMS", "", "Comma separated list of single streams to export info about streams, groups and consumers")
+		streamsExcludeConsumerMetrics  = boolFlag("streams-exclude-consumer-metrics", "REDIS_EXPORTER_STREAMS_EXCLUDE_CONSUMER_METRICS", false, "Don't collect per consumer metrics for streams (decreases cardinality)")
+		countKeys                      = stringFlag("count-keys", "REDIS_EXPORTER_COUNT_KEYS", "", "Comma separated list of patterns to count (eg: 'db0=production_*,db3=sessions:*'), searched for with SCAN")
+		checkKeysBatchSize             = int64Flag("check-keys-batch-size", "REDIS_EXPORTER_CHECK_KEYS_BATCH_SIZE", 1000, "Approximate number of keys to process in each execution, larger value speeds up scanning.\nWARNING: Still Redis is a single-threaded app, huge COUNT can affect production environment.")
+		scriptPath                     = stringFlag("script", "REDIS_EXPORTER_SCRIPT", "", "Comma separated list of path(s) to Redis Lua script(s) for gathering extra metrics")
+		listenAddress                  = stringFlag("web.listen-address", "REDIS_EXPORTER_WEB_LISTEN_ADDRESS", ":9121", "Address to listen on for web interface and telemetry.")
+		metricPath                     = stringFlag("web.telemetry-path", "REDIS_EXPORTER_WEB_TELEMETRY_PATH", "/metrics", "Path under which to expose metrics.")
+		logFormat                      = stringFlag("log-format", "REDIS_EXPORTER_LOG_FORMAT", "txt", "Log format, valid options are txt and json")
+		configCommand                  = stringFlag("config-command", "REDIS_EXPORTER_CONFIG_COMMAND", "CONFIG", "What to use for the CONFIG command, set to \"-\" to skip config metrics extraction")
+		connectionTimeout              = stringFlag("connection-timeout", "REDIS_EXPORTER_CONNECTION_TIMEOUT", "15s", "Timeout for connection to Redis instance")
+		scrapeTimeoutOffset            = stringFlag("scrape-timeout-offset", "REDIS_EXPORTER_SCRAPE_TIMEOUT_OFFSET", "500ms", "Offset to subtract from the scrape timeout sent by Prometheus, leaves time to send the metrics")
+		collectorTimeouts              = stringFlag("collector-timeouts", "REDIS_EXPORTER_COLLECTOR_TIMEOUTS", "", "Comma separated list of time budgets per collector (eg: 'key_groups=10s,check_keys=5s')")
+		metricsAllowlist               = stringFlag("metrics-allowlist", "REDIS_EXPORTER_METRICS_ALLOWLIST", "", "Regex of the metric names to export (eg: 'redis_(up|memory_.*)'), all others are dropped")
+		metricsDenylist                = stringFlag("metrics-denylist", "REDIS_EXPORTER_METRICS_DENYLIST", "", "Regex of the metric names to drop (eg: 'redis_connected_client_.*')")
+		metricsLabelFilters            = stringFlag("metrics-label-filters", "REDIS_EXPORTER_METRICS_LABEL_FILTERS", "", "Comma separated list of label matchers, series with a label value that doesn't match are dropped (eg: 'cmd!~\"config\\\\|.*\"')")
+		backgroundCollectors           = stringFlag("background-collectors", "REDIS_EXPORTER_BACKGROUND_COLLECTORS", "", "Comma separated list of collectors to run in the background with their interval (eg: 'key_groups=10m,count_keys=5m'), scrapes return the last result")
+		otlpEndpoint                   = stringFlag("otlp.endpoint", "REDIS_EXPORTER_OTLP_ENDPOINT", "", "URL of an OpenTelemetry collector to push the metrics to via OTLP (eg: 'http://otel-collector:4318')")
+		otlpProtocol                   = stringFlag("otlp.protocol", "REDIS_EXPORTER_OTLP_PROTOCOL", exporter.OTLPProtocolHTTP, "OTLP protocol, valid options are http/protobuf and grpc")
+		otlpInterval                   = stringFlag("otlp.interval", "REDIS_EXPORTER_OTLP_INTERVAL", "30s", "Interval to push the metrics via OTLP")
+		otlpTimeout                    = stringFlag("otlp.timeout", "REDIS_EXPORTER_OTLP_TIMEOUT", "10s", "Timeout for scraping and pushing the metrics via OTLP")
+		otlpHeaders                    = stringFlag("otlp.headers", "REDIS_EXPORTER_OTLP_HEADERS", "", "Comma separated list of headers to send with the OTLP requests (eg: 'authorization=Bearer xyz')")
+		remoteWriteURL                 = stringFlag("remote-write.url", "REDIS_EXPORTER_REMOTE_WRITE_URL", "", "URL of a Prometheus remote-write receiver to push the metrics to (eg: 'http://prometheus:9090/api/v1/write')")
+		remoteWriteInterval            = stringFlag("remote-write.interval", "REDIS_EXPORTER_REMOTE_WRITE_INTERVAL", "30s", "Interval to push the metrics via remote-write")
+		remoteWriteTimeout             = stringFlag("remote-write.timeout", "REDIS_EXPORTER_REMOTE_WRITE_TIMEOUT", "10s", "Timeout for scraping and for each remote-write request")
+		remoteWriteUsername            = stringFlag("remote-write.username", "REDIS_EXPORTER_REMOTE_WRITE_USERNAME", "", "Username for basic authentication with the remote-write receiver")
+		remoteWritePassword            = stringFlag("remote-write.password", "REDIS_EXPORTER_REMOTE_WRITE_PASSWORD", "", "Password for basic authentication with the remote-write receiver")
+		remoteWriteBearerToken         = stringFlag("remote-write.bearer-token", "REDIS_EXPORTER_REMOTE_WRITE_BEARER_TOKEN", "", "Bearer token for the remote-write receiver")
+		remoteWriteMaxRetries          = int64Flag("remote-write.max-retries", "REDIS_EXPORTER_REMOTE_WRITE_MAX_RETRIES", 3, "Number of retries of a failed remote-write request, with exponential backoff")
+		remoteWriteExternalLabels      = stringFlag("remote-write.external-labels", "REDIS_EXPORTER_REMOTE_WRITE_EXTERNAL_LABELS", "", "Comma separated list of labels to add to all series sent via remote-write (eg: 'cluster=prod,region=eu')")
+		remoteWriteQueueSize           = int64Flag("remote-write.queue-size", "REDIS_EXPORTER_REMOTE_WRITE_QUEUE_SIZE", 10, "Maximum number of scrapes to keep in memory while the remote-write receiver is unavailable, the oldest are dropped first")
+		connectionPool                 = boolFlag("connection-pool", "REDIS_EXPORTER_CONNECTION_POOL", false, "Whether to keep connections to the Redis instances open between scrapes")
+		connectionPoolMaxIdle          = int64Flag("connection-pool-max-idle", "REDIS_EXPORTER_CONNECTION_POOL_MAX_IDLE", 2, "Maximum number of idle pooled connections per Redis instance")
+		connectionPoolIdleTimeout      = stringFlag("connection-pool-idle-timeout", "REDIS_EXPORTER_CONNECTION_POOL_IDLE_TIMEOUT", "5m", "Close pooled connections that have been idle for longer than this, 0 keeps them open")
+		tlsClientKeyFile               = stringFlag("tls-client-key-file", "REDIS_EXPORTER_TLS_CLIENT_KEY_FILE", "", "Name of the client key file (including full path) if the server requires TLS client authentication")
+		tlsClientCertFile              = stringFlag("tls-client-cert-file", "REDIS_EXPORTER_TLS_CLIENT_CERT_FILE", "", "Name of the client certificate file (including full path) if the server requires TLS client authentication")
+		tlsCaCertFile                  = stringFlag("tls-ca-cert-file", "REDIS_EXPORTER_TLS_CA_CERT_FILE", "", "Name of the CA certificate file (including full path) if the server requires TLS client authentication")
+		tlsServerKeyFile               = stringFlag("tls-server-key-file", "REDIS_EXPORTER_TLS_SERVER_KEY_FILE", "", "Name of the server key file (including full path) if the web interface and telemetry should use TLS")
+		tlsServerCertFile              = stringFlag("tls-server-cert-file", "REDIS_EXPORTER_TLS_SERVER_CERT_FILE", "", "Name of the server certificate file (including full path) if the web interface and telemetry should use TLS")
+		tlsServerCaCertFile            = stringFlag("tls-server-ca-cert-file", "REDIS_EXPORTER_TLS_SERVER_CA_CERT_FILE", "", "Name of the CA certificate file (including full path) if the web interface and telemetry should require TLS client authentication")
+		tlsServerMinVersion            = stringFlag("tls-server-min-version", "REDIS_EXPORTER_TLS_SERVER_MIN_VERSION", "TLS1.2", "Minimum TLS version that is acceptable by the web interface and telemetry when using TLS")
+		maxDistinctKeyGroups           = int64Flag("max-distinct-key-groups", "REDIS_EXPORTER_MAX_DISTINCT_KEY_GROUPS", 100, "The maximum number of distinct key groups with the most memory utilization to present as distinct metrics per database, the leftover key groups will be aggregated in the 'overflow' bucket")
+		isDebug                        = boolFlag("debug", "REDIS_EXPORTER_DEBUG", false, "Output verbose debug information")
+		setClientName                  = boolFlag("set-client-name", "REDIS_EXPORTER_SET_CLIENT_NAME", true, "Whether to set client name to redis_exporter")
+		resp3                          = boolFlag("resp3", "REDIS_EXPORTER_RESP3", false, "Whether to negotiate RESP3 with HELLO 3 when connecting to Redis (requires Redis 6+)")
+		isTile38                       = boolFlag("is-tile38", "REDIS_EXPORTER_IS_TILE38", false, "Whether to scrape Tile38 specific metrics")
+		isCluster                      = boolFlag("is-cluster", "REDIS_EXPORTER_IS_CLUSTER", false, "Whether this is a redis cluster (Enable this if you need to fetch key level data on a Redis Cluster).")
+		exportClientList               = boolFlag("export-client-list", "REDIS_EXPORTER_EXPORT_CLIENT_LIST", false, "Whether to scrape Client List specific metrics")
+		exportClientPort               = boolFlag("export-client-port", "REDIS_EXPORTER_EXPORT_CLIENT_PORT", false, "Whether to include the client's port when exporting the client list. Warning: including the port increases the number of metrics generated and will make your Prometheus server take up more memory")
 		showVersion                    = flag.Bool("version", false, "Show version information and exit")
-		redisMetricsOnly               = flag.Bool("redis-only-metrics", getEnvBool("REDIS_EXPORTER_REDIS_ONLY_METRICS", false), "Whether to also export go runtime metrics")
-		pingOnConnect                  = flag.Bool("ping-on-connect", getEnvBool("REDIS_EXPORTER_PING_ON_CONNECT", false), "Whether to ping the redis instance after connecting")
-		inclConfigMetrics              = flag.Bool("include-config-metrics", getEnvBool("REDIS_EXPORTER_INCL_CONFIG_METRICS", false), "Whether to include all config settings as metrics")
-		inclModulesMetrics             = flag.Bool("include-modules-metrics", getEnvBool("REDIS_EXPORTER_INCL_MODULES_METRICS", false), "Whether to collect Redis Modules metrics")
-		disableExportingKeyValues      = flag.Bool("disable-exporting-key-values", getEnvBool("REDIS_EXPORTER_DISABLE_EXPORTING_KEY_VALUES", false), "Whether to disable values of keys stored in redis as labels or not when using check-keys/check-single-key")
-		excludeLatencyHistogramMetrics = flag.Bool("exclude-latency-histogram-metrics", getEnvBool("REDIS_EXPORTER_EXCLUDE_LATENCY_HISTOGRAM_METRICS", false), "Do not try to collect latency histogram metrics")
-		redactConfigMetrics            = flag.Bool("redact-config-metrics", getEnvBool("REDIS_EXPORTER_REDACT_CONFIG_METRICS", true), "Whether to redact config settings that include potentially sensitive information like passwords")
-		inclSystemMetrics              = flag.Bool("include-system-metrics", getEnvBool("REDIS_EXPORTER_INCL_SYSTEM_METRICS", false), "Whether to include system metrics like e.g. redis_total_system_memory_bytes")
-		skipTLSVerification            = flag.Bool("skip-tls-verification", getEnvBool("REDIS_EXPORTER_SKIP_TLS_VERIFICATION", false), "Whether to to skip TLS verification")
-		skipCheckKeysForRoleMaster     = flag.Bool("skip-checkkeys-for-role-master", getEnvBool("REDIS_EXPORTER_SKIP_CHECKKEYS_FOR_ROLE_MASTER", false), "Whether to skip gathering the check-keys metrics (size, val) when the instance is of type master (reduce load on master nodes)")
-		basicAuthUsername              = flag.String("basic-auth-username", getEnv("REDIS_EXPORTER_BASIC_AUTH_USERNAME", ""), "Username for basic authentication")
-		basicAuthPassword              = flag.String("basic-auth-password", getEnv("REDIS_EXPORTER_BASIC_AUTH_PASSWORD", ""), "Password for basic authentication")
-		inclMetricsForEmptyDatabases   = flag.Bool("include-metrics-for-empty-databases", getEnvBool("REDIS_EXPORTER_INCL_METRICS_FOR_EMPTY_DATABASES", true), "Whether to emit db metrics (like db_keys) for empty databases")
+		once                           = flag.Bool("once", false, "Scrape the Redis instance once, print the metrics and exit, the exit code is 1 if the instance is down")
+		offlineDir                     = stringFlag("offline", "REDIS_EXPORTER_OFFLINE", "", "Directory with captured output of INFO ALL, CONFIG GET *, CLIENT LIST, SLOWLOG GET and CLUSTER INFO to export the metrics of instead of scraping Redis")
+		recordDir                      = stringFlag("record", "REDIS_EXPORTER_RECORD", "", "Directory to record the commands sent to Redis and the replies to (eg: to attach them to a bug report)")
+		replayDir                      = stringFlag("replay", "REDIS_EXPORTER_REPLAY", "", "Directory with a recording made with --record to serve the replies of instead of connecting to Redis")
+		outputFile                     = flag.String("output", "", "With --once, write the metrics atomically to this file instead of stdout (eg: for the node_exporter textfile collector)")
+		redisMetricsOnly               = boolFlag("redis-only-metrics", "REDIS_EXPORTER_REDIS_ONLY_METRICS", false, "Whether to also export go runtime metrics")
+		pingOnConnect                  = boolFlag("ping-on-connect", "REDIS_EXPORTER_PING_ON_CONNECT", false, "Whether to ping the redis instance after connecting")
+		inclConfigMetrics              = boolFlag("include-config-metrics", "REDIS_EXPORTER_INCL_CONFIG_METRICS", false, "Whether to include all config settings as metrics")
+		inclModulesMetrics             = boolFlag("include-modules-metrics", "REDIS_EXPORTER_INCL_MODULES_METRICS", false, "Whether to collect Redis Modules metrics")
+		disableExportingKeyValues      = boolFlag("disable-exporting-key-values", "REDIS_EXPORTER_DISABLE_EXPORTING_KEY_VALUES", false, "Whether to disable values of keys stored in redis as labels or not when using check-keys/check-single-key")
+		excludeLatencyHistogramMetrics = boolFlag("exclude-latency-histogram-metrics", "REDIS_EXPORTER_EXCLUDE_LATENCY_HISTOGRAM_METRICS", false, "Do not try to collect latency histogram metrics")
+		redactConfigMetrics            = boolFlag("redact-config-metrics", "REDIS_EXPORTER_REDACT_CONFIG_METRICS", true, "Whether to redact config settings that include potentially sensitive information like passwords")
+		inclSystemMetrics              = boolFlag("include-system-metrics", "REDIS_EXPORTER_INCL_SYSTEM_METRICS", false, "Whether to include system metrics like e.g. redis_total_system_memory_bytes")
+		skipTLSVerification            = boolFlag("skip-tls-verification", "REDIS_EXPORTER_SKIP_TLS_VERIFICATION", false, "Whether to to skip TLS verification")
+		skipCheckKeysForRoleMaster     = boolFlag("skip-checkkeys-for-role-master", "REDIS_EXPORTER_SKIP_CHECKKEYS_FOR_ROLE_MASTER", false, "Whether to skip gathering the check-keys metrics (size, val) when the instance is of type master (reduce load on master nodes)")
+		basicAuthUsername              = stringFlag("basic-auth-username", "REDIS_EXPORTER_BASIC_AUTH_USERNAME", "", "Username for basic authentication")
+		basicAuthPassword              = stringFlag("basic-auth-password", "REDIS_EXPORTER_BASIC_AUTH_PASSWORD", "", "Password for basic authentication")
+		scrapeAllowedTargets           = stringFlag("scrape-allowed-targets", "REDIS_EXPORTER_SCRAPE_ALLOWED_TARGETS", "", "Comma separated list of CIDRs, hostnames and hostname globs of the targets the /scrape endpoint may connect to, all targets are allowed if empty")
+		scrapeMaxConcurrent            = int64Flag("scrape-max-concurrent", "REDIS_EXPORTER_SCRAPE_MAX_CONCURRENT", 0, "Maximum number of requests to the /scrape endpoint in flight, further requests get a 429 response, 0 is unlimited")
+		scrapeOnePerTarget             = boolFlag("scrape-one-per-target", "REDIS_EXPORTER_SCRAPE_ONE_PER_TARGET", false, "Whether to allow one scrape of a target at a time via the /scrape endpoint, overlapping requests with other parameters get a 429 response")
+		scrapeRateLimit                = stringFlag("scrape-rate-limit", "REDIS_EXPORTER_SCRAPE_RATE_LIMIT", "", "Maximum number of requests per second to the /scrape endpoint, further requests get a 429 response, unlimited if empty")
+		scrapeRateBurst                = int64Flag("scrape-rate-burst", "REDIS_EXPORTER_SCRAPE_RATE_BURST", 0, "Number of requests to the /scrape endpoint allowed at once by scrape-rate-limit, defaults to the rate")
+		scrapeExporterCacheSize        = int64Flag("scrape-exporter-cache-size", "REDIS_EXPORTER_SCRAPE_EXPORTER_CACHE_SIZE", 1000, "Number of exporters of the /scrape endpoint kept for the next scrapes of their target, 0 creates a new one for every request")
+		scrapeExporterCacheIdle        = stringFlag("scrape-exporter-cache-idle-timeout", "REDIS_EXPORTER_SCRAPE_EXPORTER_CACHE_IDLE_TIMEOUT", "10m", "Cached exporters of the /scrape endpoint that haven't been used for this long are removed, 0 keeps them")
+		healthCheckTimeout             = stringFlag("health-check-timeout", "REDIS_EXPORTER_HEALTH_CHECK_TIMEOUT", "3s", "Timeout of the checks of /ready and /health?target= (in Golang duration format)")
+		scrapeKnownTargetsOnly         = boolFlag("scrape-known-targets-only", "REDIS_EXPORTER_SCRAPE_KNOWN_TARGETS_ONLY", false, "Whether the /scrape endpoint only connects to the targets of the password file and the config file (and the ones of scrape-allowed-targets)")
+		webConfigFile                  = stringFlag("web.config.file", "REDIS_EXPORTER_WEB_CONFIG_FILE", "", "Path to a web config file with the users and bearer tokens that can access the endpoints, it's reloaded when it changes")
+		inclMetricsForEmptyDatabases   = boolFlag("include-metrics-for-empty-databases", "REDIS_EXPORTER_INCL_METRICS_FOR_EMPTY_DATABASES", true, "Whether to emit db metrics (like db_keys) for empty databases")
 	)
 	flag.Parse()
 
+	// settings given on the command line or via environment variables win over the config file
+	pinned := pinnedFlags()
+	fileCfg := &exporter.Config{}
+	if *configFile != "" {
+		cfg, _, err := applyConfigFile(*configFile, pinned, false)
+		if err != nil {
+			log.Fatalf("Error loading config file %s, err: %s", *configFile, err)
+		}
+		fileCfg = cfg
+	}
+
 	switch *logFormat {
 	case "json":
 		log.SetFormatter(&log.JSONFormatter{})
@@ -133,108 +294,329 @@ func main() {
 		log.SetLevel(log.InfoLevel)
 	}
 
-	to, err := time.ParseDuration(*connectionTimeout)
-	if err != nil {
-		log.Fatalf("Couldn't parse connection timeout duration, err: %s", err)
+	var (
+		current   atomic.Pointer[exporter.Exporter]
+		reloadMtx sync.Mutex
+		reload    func() error
+	)
+
+	var (
+		recorder *exporter.Recorder
+		replay   *exporter.Replay
+		err      error
+	)
+	if *recordDir != "" && *replayDir != "" {
+		log.Fatal("--record and --replay can't be used together")
+	}
+	if *recordDir != "" {
+		if recorder, err = exporter.NewRecorder(*recordDir); err != nil {
+			log.Fatalf("Error creating recorder, err: %s", err)
+		}
+		defer recorder.Close()
+		log.Infof("Recording the commands sent to Redis to %s", *recordDir)
+	}
+	var webAuth *exporter.WebAuth
+	if *webConfigFile != "" {
+		if *basicAuthUsername != "" || *basicAuthPassword != "" {
+			log.Fatal("--web.config.file and --basic-auth-username/--basic-auth-password can't be used together")
+		}
+		if webAuth, err = exporter.NewWebAuth(*webConfigFile); err != nil {
+			log.Fatalf("Error loading web config file, err: %s", err)
+		}
 	}
 
-	passwordMap := make(map[string]string)
-	if *redisPwd == "" && *redisPwdFile != "" {
-		passwordMap, err = exporter.LoadPwdFile(*redisPwdFile)
+	var credentialProvider exporter.CredentialProvider
+	if *redisCredentialCommand != "" {
+		to, err := time.ParseDuration(*connectionTimeout)
 		if err != nil {
-			log.Fatalf("Error loading redis passwords from file %s, err: %s", *redisPwdFile, err)
+			log.Fatalf("Couldn't parse connection timeout duration, err: %s", err)
+		}
+		// created once so the cached credentials are kept when the config is reloaded
+		if credentialProvider, err = exporter.NewExecCredentialProvider(strings.Fields(*redisCredentialCommand), to); err != nil {
+			log.Fatalf("Error creating credential provider, err: %s", err)
 		}
 	}
+	if *replayDir != "" {
+		if replay, err = exporter.LoadReplay(*replayDir); err != nil {
+			log.Fatalf("Error loading recording, err: %s", err)
+		}
+		log.Infof("Replaying the recording in %s instead of connecting to Redis", *replayDir)
+	}
+
+	buildExporter := func() (*exporter.Exporter, error) {
+		to, err := time.ParseDuration(*connectionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse connection timeout duration, err: %s", err)
+		}
 
-	var ls map[string][]byte
-	if *scriptPath != "" {
-		scripts := strings.Split(*scriptPath, ",")
-		ls = make(map[string][]byte, len(scripts))
-		for _, script := range scripts {
-			if ls[script], err = os.ReadFile(script); err != nil {
-				log.Fatalf("Error loading script file %s    err: %s", script, err)
+		timeoutOffset, err := time.ParseDuration(*scrapeTimeoutOffset)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse scrape timeout offset duration, err: %s", err)
+		}
+
+		collTimeouts, err := exporter.ParseCollectorTimeouts(*collectorTimeouts)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse collector timeouts, err: %s", err)
+		}
+
+		metricFilter, err := exporter.NewMetricFilter(*metricsAllowlist, *metricsDenylist, *metricsLabelFilters)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse metric filters, err: %s", err)
+		}
+
+		var targetAllowlist *exporter.TargetAllowlist
+		if *scrapeAllowedTargets != "" {
+			if targetAllowlist, err = exporter.NewTargetAllowlist(strings.Split(*scrapeAllowedTargets, ",")); err != nil {
+				return nil, fmt.Errorf("couldn't parse scrape allowed targets, err: %s", err)
 			}
 		}
-	}
 
-	registry := prometheus.NewRegistry()
-	if !*redisMetricsOnly {
-		registry.MustRegister(
-			// expose process metrics like CPU, Memory, file descriptor usage etc.
-			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
-			// expose all Go runtime metrics like GC stats, memory stats etc.
-			collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsAll)),
+		bgCollectors, err := exporter.ParseBackgroundCollectors(*backgroundCollectors)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse background collectors, err: %s", err)
+		}
+		metricsOnly := *redisMetricsOnly
+		if *once || *outputFile != "" {
+			// there's no later scrape that could return the results of a background run
+			bgCollectors = nil
+			// the metrics of a one-off process aren't interesting, they'd only clutter the output
+			metricsOnly = true
+		}
+
+		poolIdleTimeout, err := time.ParseDuration(*connectionPoolIdleTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse connection pool idle timeout duration, err: %s", err)
+		}
+
+		cacheIdleTimeout, err := time.ParseDuration(*scrapeExporterCacheIdle)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse scrape exporter cache idle timeout duration, err: %s", err)
+		}
+
+		healthTimeout, err := time.ParseDuration(*healthCheckTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse health check timeout duration, err: %s", err)
+		}
+
+		var rateLimit float64
+		if *scrapeRateLimit != "" {
+			if rateLimit, err = strconv.ParseFloat(*scrapeRateLimit, 64); err != nil || rateLimit < 0 {
+				return nil, fmt.Errorf("invalid scrape rate limit %q", *scrapeRateLimit)
+			}
+		}
+
+		var dump *exporter.Dump
+		if *offlineDir != "" {
+			if len(fileCfg.Targets) > 0 {
+				return nil, errors.New("offline mode doesn't support the targets of the config file")
+			}
+			if dump, err = exporter.LoadDump(*offlineDir); err != nil {
+				return nil, fmt.Errorf("error loading dump from %s, err: %s", *offlineDir, err)
+			}
+		}
+
+		passwordMap := make(map[string]string)
+		if *redisPwd == "" && *redisPwdFile != "" {
+			passwordMap, err = exporter.LoadPwdFile(*redisPwdFile)
+			if err != nil {
+				return nil, fmt.Errorf("error loading redis passwords from file %s, err: %s", *redisPwdFile, err)
+			}
+		}
+
+		var ls map[string][]byte
+		if *scriptPath != "" {
+			scripts := strings.Split(*scriptPath, ",")
+			ls = make(map[string][]byte, len(scripts))
+			for _, script := range scripts {
+				if ls[script], err = os.ReadFile(script); err != nil {
+					return nil, fmt.Errorf("error loading script file %s    err: %s", script, err)
+				}
+			}
+		}
+
+		registry := prometheus.NewRegistry()
+		if !metricsOnly {
+			registry.MustRegister(
+				// expose process metrics like CPU, Memory, file descriptor usage etc.
+				collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+				// expose all Go runtime metrics like GC stats, memory stats etc.
+				collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsAll)),
+			)
+		}
+
+		var configReloader func() error
+		if *configFile != "" {
+			configReloader = reload
+		}
+
+		exp, err := exporter.NewRedisExporter(
+			*redisAddr,
+			exporter.Options{
+				User:                           *redisUser,
+				Password:                       *redisPwd,
+				PasswordMap:                    passwordMap,
+				CredentialProvider:             credentialProvider,
+				Namespace:                      *namespace,
+				ConfigCommandName:              *configCommand,
+				CheckKeys:                      *checkKeys,
+				CheckSingleKeys:                *checkSingleKeys,
+				CheckKeysBatchSize:             *checkKeysBatchSize,
+				CheckKeyGroups:                 *checkKeyGroups,
+				MaxDistinctKeyGroups:           *maxDistinctKeyGroups,
+				CheckStreams:                   *checkStreams,
+				CheckSingleStreams:             *checkSingleStreams,
+				StreamsExcludeConsumerMetrics:  *streamsExcludeConsumerMetrics,
+				CountKeys:                      *countKeys,
+				LuaScript:                      ls,
+				InclSystemMetrics:              *inclSystemMetrics,
+				InclConfigMetrics:              *inclConfigMetrics,
+				DisableExportingKeyValues:      *disableExportingKeyValues,
+				ExcludeLatencyHistogramMetrics: *excludeLatencyHistogramMetrics,
+				RedactConfigMetrics:            *redactConfigMetrics,
+				SetClientName:                  *setClientName,
+				RESP3:                          *resp3,
+				IsTile38:                       *isTile38,
+				IsCluster:                      *isCluster,
+				InclModulesMetrics:             *inclModulesMetrics,
+				ExportClientList:               *exportClientList,
+				ExportClientsInclPort:          *exportClientPort,
+				SkipCheckKeysForRoleMaster:     *skipCheckKeysForRoleMaster,
+				SkipTLSVerification:            *skipTLSVerification,
+				ClientCertFile:                 *tlsClientCertFile,
+				ClientKeyFile:                  *tlsClientKeyFile,
+				CaCertFile:                     *tlsCaCertFile,
+				ConnectionTimeouts:             to,
+				MetricsPath:                    *metricPath,
+				RedisMetricsOnly:               metricsOnly,
+				PingOnConnect:                  *pingOnConnect,
+				RedisPwdFile:                   *redisPwdFile,
+				Registry:                       registry,
+				BuildInfo: exporter.BuildInfo{
+					Version:   BuildVersion,
+					CommitSha: BuildCommitSha,
+					Date:      BuildDate,
+				},
+				BasicAuthUsername:            *basicAuthUsername,
+				BasicAuthPassword:            *basicAuthPassword,
+				WebAuth:                      webAuth,
+				ScrapeTargetAllowlist:        targetAllowlist,
+				ScrapeKnownTargetsOnly:       *scrapeKnownTargetsOnly,
+				ScrapeMaxConcurrent:          int(*scrapeMaxConcurrent),
+				ScrapeOnePerTarget:           *scrapeOnePerTarget,
+				ScrapeRateLimit:              rateLimit,
+				ScrapeRateBurst:              int(*scrapeRateBurst),
+				ScrapeCacheSize:              int(*scrapeExporterCacheSize),
+				ScrapeCacheIdleTimeout:       cacheIdleTimeout,
+				HealthCheckTimeout:           healthTimeout,
+				InclMetricsForEmptyDatabases: *inclMetricsForEmptyDatabases,
+				ScrapeTimeoutOffset:          timeoutOffset,
+				CollectorTimeouts:            collTimeouts,
+				Targets:                      fileCfg.Targets,
+				Modules:                      fileCfg.Modules,
+				ConfigReloader:               configReloader,
+				MetricFilter:                 metricFilter,
+				BackgroundCollectors:         bgCollectors,
+				Dump:                         dump,
+				Recorder:                     recorder,
+				Replay:                       replay,
+				ConnectionPool:               *connectionPool,
+				ConnectionPoolMaxIdle:        int(*connectionPoolMaxIdle),
+				ConnectionPoolIdleTimeout:    poolIdleTimeout,
+			},
 		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Verify that initial client keypair and CA are accepted
+		if (*tlsClientCertFile != "") != (*tlsClientKeyFile != "") {
+			return nil, errors.New("TLS client key file and cert file should both be present")
+		}
+		if _, err := exp.CreateClientTLSConfig(); err != nil {
+			exp.Stop()
+			return nil, err
+		}
+		return exp, nil
 	}
 
-	exp, err := exporter.NewRedisExporter(
-		*redisAddr,
-		exporter.Options{
-			User:                           *redisUser,
-			Password:                       *redisPwd,
-			PasswordMap:                    passwordMap,
-			Namespace:                      *namespace,
-			ConfigCommandName:              *configCommand,
-			CheckKeys:                      *checkKeys,
-			CheckSingleKeys:                *checkSingleKeys,
-			CheckKeysBatchSize:             *checkKeysBatchSize,
-			CheckKeyGroups:                 *checkKeyGroups,
-			MaxDistinctKeyGroups:           *maxDistinctKeyGroups,
-			CheckStreams:                   *checkStreams,
-			CheckSingleStreams:             *checkSingleStreams,
-			StreamsExcludeConsumerMetrics:  *streamsExcludeConsumerMetrics,
-			CountKeys:                      *countKeys,
-			LuaScript:                      ls,
-			InclSystemMetrics:              *inclSystemMetrics,
-			InclConfigMetrics:              *inclConfigMetrics,
-			DisableExportingKeyValues:      *disableExportingKeyValues,
-			ExcludeLatencyHistogramMetrics: *excludeLatencyHistogramMetrics,
-			RedactConfigMetrics:            *redactConfigMetrics,
-			SetClientName:                  *setClientName,
-			IsTile38:                       *isTile38,
-			IsCluster:                      *isCluster,
-			InclModulesMetrics:             *inclModulesMetrics,
-			ExportClientList:               *exportClientList,
-			ExportClientsInclPort:          *exportClientPort,
-			SkipCheckKeysForRoleMaster:     *skipCheckKeysForRoleMaster,
-			SkipTLSVerification:            *skipTLSVerification,
-			ClientCertFile:                 *tlsClientCertFile,
-			ClientKeyFile:                  *tlsClientKeyFile,
-			CaCertFile:                     *tlsCaCertFile,
-			ConnectionTimeouts:             to,
-			MetricsPath:                    *metricPath,
-			RedisMetricsOnly:               *redisMetricsOnly,
-			PingOnConnect:                  *pingOnConnect,
-			RedisPwdFile:                   *redisPwdFile,
-			Registry:                       registry,
-			BuildInfo: exporter.BuildInfo{
-				Version:   BuildVersion,
-				CommitSha: BuildCommitSha,
-				Date:      BuildDate,
-			},
-			BasicAuthUsername:            *basicAuthUsername,
-			BasicAuthPassword:            *basicAuthPassword,
-			InclMetricsForEmptyDatabases: *inclMetricsForEmptyDatabases,
-		},
-	)
+	reload = func() error {
+		reloadMtx.Lock()
+		defer reloadMtx.Unlock()
+
+		cfg, restore, err := applyConfigFile(*configFile, pinned, true)
+		if err != nil {
+			return err
+		}
+		prevCfg := fileCfg
+		fileCfg = cfg
+		exp, err := buildExporter()
+		if err != nil {
+			restore()
+			fileCfg = prevCfg
+			return err
+		}
+		// in-flight scrapes of the previous exporter close their pooled connections when they are done
+		current.Swap(exp).Stop()
+		log.Infof("Reloaded config file %s", *configFile)
+		return nil
+	}
+
+	exp, err := buildExporter()
 	if err != nil {
 		log.Fatal(err)
 	}
+	current.Store(exp)
+
+	if *once {
+		var up bool
+		if *outputFile != "" {
+			up, err = exp.WriteMetricsFile(context.Background(), *outputFile)
+		} else {
+			up, err = exp.WriteMetrics(context.Background(), os.Stdout)
+		}
+		exp.Stop()
+		if recorder != nil {
+			recorder.Close()
+		}
+		if err != nil {
+			log.Fatalf("Error writing metrics, err: %s", err)
+		}
+		if !up {
+			log.Errorf("Redis instance %s is down", *redisAddr)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Verify that initial client keypair and CA are accepted
-	if (*tlsClientCertFile != "") != (*tlsClientKeyFile != "") {
-		log.Fatal("TLS client key file and cert file should both be present")
+	if *otlpEndpoint != "" {
+		pusher, err := newOTLPPusher(*otlpEndpoint, *otlpProtocol, *otlpInterval, *otlpTimeout, *otlpHeaders)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Pushing metrics via OTLP (%s) to %s every %s", *otlpProtocol, *otlpEndpoint, *otlpInterval)
+		go pusher.Run(context.Background(), current.Load)
 	}
-	_, err = exp.CreateClientTLSConfig()
-	if err != nil {
-		log.Fatal(err)
+
+	if *remoteWriteURL != "" {
+		writer, err := newRemoteWriter(*remoteWriteURL, *remoteWriteInterval, *remoteWriteTimeout, *remoteWriteUsername, *remoteWritePassword, *remoteWriteBearerToken, *remoteWriteMaxRetries, *remoteWriteQueueSize, *remoteWriteExternalLabels)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Pushing metrics via remote-write to %s every %s", *remoteWriteURL, *remoteWriteInterval)
+		go writer.Run(context.Background(), current.Load)
 	}
 
 	log.Infof("Providing metrics at %s%s", *listenAddress, *metricPath)
-	log.Debugf("Configured redis addr: %#v", *redisAddr)
+	if len(fileCfg.Targets) > 0 {
+		log.Infof("Scraping %d targets from config file %s", len(fileCfg.Targets), *configFile)
+	} else {
+		log.Debugf("Configured redis addr: %#v", *redisAddr)
+	}
 	server := &http.Server{
-		Addr:    *listenAddress,
-		Handler: exp,
+		Addr: *listenAddress,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current.Load().ServeHTTP(w, r)
+		}),
 	}
 	go func() {
 		if *tlsServerCertFile != "" && *tlsServerKeyFile != "" {
@@ -255,6 +637,20 @@ func main() {
 		}
 	}()
 
+	// installed after the flags outside of buildExporter are read, a reload doesn't set the
+	// startup-only ones that the server still reads
+	if *configFile != "" {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reload(); err != nil {
+					log.Errorf("Error reloading config file %s, err: %s", *configFile, err)
+				}
+			}
+		}()
+	}
+
 	// graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -270,3 +666,59 @@ func main() {
 	}
 	log.Infof("Server shut down gracefully")
 }
+
+func newOTLPPusher(endpoint, protocol, interval, timeout, headers string) (*exporter.OTLPPusher, error) {
+	cfg := exporter.OTLPConfig{Endpoint: endpoint, Protocol: protocol, Headers: map[string]string{}}
+
+	var err error
+	if cfg.Interval, err = time.ParseDuration(interval); err != nil {
+		return nil, fmt.Errorf("couldn't parse OTLP interval, err: %s", err)
+	}
+	if cfg.Timeout, err = time.ParseDuration(timeout); err != nil {
+		return nil, fmt.Errorf("couldn't parse OTLP timeout, err: %s", err)
+	}
+	for _, h := range strings.Split(headers, ",") {
+		if strings.TrimSpace(h) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(h, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid OTLP header %q, expected <name>=<value>", h)
+		}
+		cfg.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	return exporter.NewOTLPPusher(cfg, exporter.BuildInfo{Version: BuildVersion, CommitSha: BuildCommitSha, Date: BuildDate})
+}
+
+func newRemoteWriter(url, interval, timeout, username, password, bearerToken string, maxRetries, queueSize int64, externalLabels string) (*exporter.RemoteWriter, error) {
+	cfg := exporter.RemoteWriteConfig{
+		URL:            url,
+		Username:       username,
+		Password:       password,
+		BearerToken:    bearerToken,
+		MaxRetries:     int(maxRetries),
+		MaxBackoff:     5 * time.Second,
+		QueueSize:      int(queueSize),
+		ExternalLabels: map[string]string{},
+	}
+
+	var err error
+	if cfg.Interval, err = time.ParseDuration(interval); err != nil {
+		return nil, fmt.Errorf("couldn't parse remote-write interval, err: %s", err)
+	}
+	if cfg.Timeout, err = time.ParseDuration(timeout); err != nil {
+		return nil, fmt.Errorf("couldn't parse remote-write timeout, err: %s", err)
+	}
+	for _, l := range strings.Split(externalLabels, ",") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(l, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid remote-write external label %q, expected <name>=<value>", l)
+		}
+		cfg.ExternalLabels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return exporter.NewRemoteWriter(cfg)
+}